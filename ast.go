@@ -1,18 +1,47 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/alecthomas/participle/lexer"
 )
 
+// Node is a node of an expression syntax tree
+type Node interface {
+	// Position returns the position of the node in source
+	Position() lexer.Position
+
+	format(b *strings.Builder)
+}
+
 // Expression represents basic expression syntax that can be evaluated for an Instance
 type Expression struct {
 	Pos lexer.Position
 
-	Comparison *Comparison `@@`
+	Let        *Let        `  @@`
+	Comparison *Comparison `| @@`
 	Op         *string     `[ @( "|" "|" | "&" "&" )`
 	Next       *Expression `  @@ ]`
 }
 
+// Let binds values to variables in the scope of the body expression, which extends as far as
+// possible. Each binding is visible to the following ones and shadows variables of the instance
+// and of enclosing let expressions. Bound values are terms, so comparisons need parentheses.
+type Let struct {
+	Pos lexer.Position
+
+	Bindings []*Binding  `"let" @@ { "," @@ }`
+	Body     *Expression `"in" @@`
+}
+
+// Binding represents a variable binding of a let expression
+type Binding struct {
+	Pos lexer.Position
+
+	Name  string `@Ident "="`
+	Value *Term  `@@`
+}
+
 // Iterable represents an iterable expration that can be evaluated for an Iterator
 type IterableExpression struct {
 	Pos lexer.Position
@@ -30,7 +59,9 @@ type IterableComparison struct {
 	ScalarComparison *ScalarComparison `[ @@ ]`
 }
 
-// PathExpression represents an expression evaluating to a file path or file glob
+// PathExpression represents an expression evaluating to a file path or file glob.
+// Unquoted paths may contain wildcards and character classes in any segment, while spaces
+// and other special characters need to be escaped with a backslash or the path quoted.
 type PathExpression struct {
 	Pos lexer.Position
 
@@ -38,11 +69,13 @@ type PathExpression struct {
 	Expression *Expression `| @@`
 }
 
-// Comparison represents syntax for comparison operations
+// Comparison represents syntax for comparison operations, where the lhs is either a term or
+// an array literal such as [1, 2] any of [2, 3]
 type Comparison struct {
 	Pos lexer.Position
 
-	Term             *Term             `@@`
+	Values           []Value           `(  "[" @@ { "," @@ } "]"`
+	Term             *Term             `| @@ )`
 	ScalarComparison *ScalarComparison `[ @@`
 	ArrayComparison  *ArrayComparison  `| @@ ]`
 }
@@ -51,25 +84,29 @@ type Comparison struct {
 type ScalarComparison struct {
 	Pos lexer.Position
 
-	Op   *string     `@( ">" "=" | "<" "=" | ">" | "<" | "!" "=" | "=" "=" | "=" "~" | "!" "~" )`
+	Op   *string     `@( ">" "=" | "<" "=" | ">" | "<" | "!" "=" "~" | "=" "=" "~" | "!" "=" | "=" "=" | "=" "~" | "!" "~" | [ "not" ] ( "like" | "ilike" | "glob" | "iglob" | "contains" ) )`
 	Next *Comparison `  @@`
 }
 
-// ArrayComparison represents syntax for array comparison
+// ArrayComparison represents syntax for array comparison. Besides membership tests with in and
+// not in, set operators treat a scalar lhs as an array of one element:
+//   - any of checks whether lhs and rhs have at least one element in common
+//   - all of and superset check whether every element of rhs is in lhs
+//   - subset checks whether every element of lhs is in rhs
 type ArrayComparison struct {
 	Pos lexer.Position
 
-	Op *string ` ( @( "in" | "not" "in" )`
-	// TODO: FIXME: likely doesn't work with rhs expression
-	Array *Array `@@ )`
+	Op    *string ` ( @( "in" | "not" "in" | "any" "of" | "all" "of" | "subset" | "superset" )`
+	Array *Array  `@@ )`
 }
 
-// Term is an abstract term allowing optional binary bit operation syntax
+// Term is an abstract term allowing optional binary operation syntax. Additive operators bind
+// tighter than bit operators and are evaluated left to right, while bit operators group to the right.
 type Term struct {
 	Pos lexer.Position
 
 	Unary *Unary  `@@`
-	Op    *string `[ @( "&" | "|" | "^" | "+" )`
+	Op    *string `[ @( "&" | "|" | "^" | "+" | "-" )`
 	Next  *Term   `  @@ ]`
 }
 
@@ -82,25 +119,33 @@ type Unary struct {
 	Value *Value  `| @@`
 }
 
-// Array provides support for array syntax and may contain any valid Values (mixed allowed)
+// Array provides support for array syntax and may contain any valid Values (mixed allowed).
+// Arrays may also be provided by a variable or a function call.
 type Array struct {
 	Pos lexer.Position
 
 	Values []Value `"[" @@ { "," @@ } "]"`
 
+	Call  *Call   `| @@`
 	Ident *string `| @Ident`
 }
 
 // Value provides support for various value types in expression including
-// integers in various form, strings, function calls, variables and
-// subexpressions
+// integers in various form, sizes, strings, durations, function calls, variables and
+// subexpressions.
+//
+// Strings are either double-quoted, single-quoted or raw. Quoted strings support Go escape
+// sequences such as \n, \t, \\, \x41, \u00e9 and \U0001f600 along with an escaped quote
+// character, while raw strings enclosed in backticks are taken verbatim.
 type Value struct {
 	Pos lexer.Position
 
-	Hex           *string     `  @Hex`
+	Size          *Size       `  @( Size | ( "-" | "+" ) Size )`
+	Duration      *Duration   `| @( Duration | ( "-" | "+" ) Duration )`
+	Hex           *string     `| @Hex`
 	Octal         *string     `| @Octal`
-	Decimal       *int64      `| @Decimal`
-	String        *string     `| @String`
+	Decimal       *int64      `| @( Decimal | ( "-" | "+" ) Decimal )`
+	String        *string     `| @( String | RawString )`
 	Call          *Call       `| @@`
 	Variable      *string     `| @Ident`
 	Subexpression *Expression `| "(" @@ ")"`
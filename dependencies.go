@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+)
+
+// Dependencies returns sorted names of variables and functions referenced by a syntax tree,
+// such as an Expression, an IterableExpression or a PathExpression. Builtin pseudo-functions
+// of iterable expressions and variables bound by let expressions are not included.
+func Dependencies(node Node) (vars []string, funcs []string) {
+	d := &dependencies{
+		vars:  make(map[string]struct{}),
+		funcs: make(map[string]struct{}),
+		bound: make(map[string]int),
+	}
+	Walk(node, d)
+	return sortedKeys(d.vars), sortedKeys(d.funcs)
+}
+
+// dependencies collects references of a syntax tree while tracking names bound in scope
+type dependencies struct {
+	vars  map[string]struct{}
+	funcs map[string]struct{}
+	bound map[string]int
+	stack []Node
+}
+
+func (d *dependencies) Visit(node Node) Visitor {
+	if node == nil {
+		node, d.stack = d.stack[len(d.stack)-1], d.stack[:len(d.stack)-1]
+		switch node := node.(type) {
+		case *Binding:
+			// A binding is in scope of the following bindings and the body
+			d.bound[node.Name]++
+		case *Let:
+			for _, binding := range node.Bindings {
+				d.bound[binding.Name]--
+			}
+		}
+		return nil
+	}
+	d.stack = append(d.stack, node)
+
+	switch node := node.(type) {
+	case *Value:
+		if node.Variable != nil && d.bound[*node.Variable] == 0 {
+			d.vars[*node.Variable] = struct{}{}
+		}
+	case *Array:
+		if node.Ident != nil && d.bound[*node.Ident] == 0 {
+			d.vars[*node.Ident] = struct{}{}
+		}
+	case *Call:
+		d.funcs[node.Name] = struct{}{}
+	}
+	return d
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
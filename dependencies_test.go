@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestDependencies(t *testing.T) {
+	tests := []struct {
+		name        string
+		parse       func(string) (Node, error)
+		expression  string
+		expectVars  []string
+		expectFuncs []string
+	}{
+		{
+			name:        "expression",
+			parse:       func(s string) (Node, error) { return ParseExpression(s) },
+			expression:  `process.name == "sshd" && lower(file.owner) in ["root", admin] && file.group in groups() && (uid & mask) == 0`,
+			expectVars:  []string{"admin", "file.group", "file.owner", "mask", "process.name", "uid"},
+			expectFuncs: []string{"groups", "lower"},
+		},
+		{
+			name:        "array literal lhs",
+			parse:       func(s string) (Node, error) { return ParseExpression(s) },
+			expression:  `[a, f(b)] subset c`,
+			expectVars:  []string{"a", "b", "c"},
+			expectFuncs: []string{"f"},
+		},
+		{
+			name:        "duplicates",
+			parse:       func(s string) (Node, error) { return ParseExpression(s) },
+			expression:  `f(a) == f(a) && a in b && a not in b`,
+			expectVars:  []string{"a", "b"},
+			expectFuncs: []string{"f"},
+		},
+		{
+			name:        "constants",
+			parse:       func(s string) (Node, error) { return ParseExpression(s) },
+			expression:  `1 == 1 && "a" in ["a"]`,
+			expectVars:  []string{},
+			expectFuncs: []string{},
+		},
+		{
+			name:        "let",
+			parse:       func(s string) (Node, error) { return ParseExpression(s) },
+			expression:  `let a = f(b), b = a | b in a in c && b == 1 && (let c = d in c == e) && c == 2`,
+			expectVars:  []string{"b", "c", "d", "e"},
+			expectFuncs: []string{"f"},
+		},
+		{
+			name:        "iterable",
+			parse:       func(s string) (Node, error) { return ParseIterable(s) },
+			expression:  `len(process.name == name(pid)) > limit`,
+			expectVars:  []string{"limit", "pid", "process.name"},
+			expectFuncs: []string{"name"},
+		},
+		{
+			name:        "path",
+			parse:       func(s string) (Node, error) { return ParsePath(s) },
+			expression:  `home(user) + "/.ssh"`,
+			expectVars:  []string{"user"},
+			expectFuncs: []string{"home"},
+		},
+		{
+			name:        "unquoted path",
+			parse:       func(s string) (Node, error) { return ParsePath(s) },
+			expression:  `/etc/ssh/sshd_config`,
+			expectVars:  []string{},
+			expectFuncs: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			node, err := test.parse(test.expression)
+			assert.NoError(err)
+
+			vars, funcs := Dependencies(node)
+			assert.Equal(test.expectVars, vars)
+			assert.Equal(test.expectFuncs, funcs)
+		})
+	}
+}
@@ -1,7 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"net"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
 
 	"github.com/alecthomas/participle/lexer"
 	"github.com/alecthomas/repr"
@@ -12,6 +20,19 @@ type Evaluatable interface {
 	Evaluate(instance *Instance) (interface{}, error)
 }
 
+// Comparable is implemented by values supporting ordering with scalar comparison operators
+type Comparable interface {
+	// Compare returns -1, 0 or +1 depending on whether the value is less than, equal to or greater than other
+	Compare(other interface{}) (int, error)
+}
+
+// Collator orders strings according to locale-aware collation rules, as implemented by
+// golang.org/x/text/collate.Collator
+type Collator interface {
+	// CompareString returns -1, 0 or +1 depending on whether a is ordered before, equal to or after b
+	CompareString(a, b string) int
+}
+
 // Function describes a function callable for an instance
 type Function func(instance *Instance, args ...interface{}) (interface{}, error)
 
@@ -21,15 +42,158 @@ type FunctionMap map[string]Function
 // VarMap describes a map of variables
 type VarMap map[string]interface{}
 
+// VariableResolver computes variables on demand, allowing expensive facts to be collected only
+// when an expression refers to them
+type VariableResolver interface {
+	// ResolveVariable returns the value of a variable for an instance and whether it is defined
+	ResolveVariable(instance *Instance, name string) (interface{}, bool, error)
+}
+
+// VariableResolverFunc is an adapter allowing use of a function as a VariableResolver
+type VariableResolverFunc func(instance *Instance, name string) (interface{}, bool, error)
+
+// ResolveVariable calls f(instance, name)
+func (f VariableResolverFunc) ResolveVariable(instance *Instance, name string) (interface{}, bool, error) {
+	return f(instance, name)
+}
+
 // Instance for evaluation
 type Instance struct {
 	// Instance functions
 	Functions FunctionMap
 	// Vars defined during evaluation.
 	Vars VarMap
+	// Clock returns the current time, the clock of the parent or time.Now is used when not set
+	Clock func() time.Time
+	// Collator orders strings for <, >, <= and >=, the collator of the parent or byte-wise
+	// ordering is used when not set
+	Collator Collator
+	// Resolver is consulted for variables not defined in Vars. Resolved variables and errors are
+	// memoised by the instance until ResetResolved is called, so that each variable is resolved
+	// at most once, even when the instance is evaluated concurrently.
+	Resolver VariableResolver
+	// Parent is an enclosing scope consulted for variables and functions not defined by the instance
+	Parent *Instance
+
+	// resolved points to the resolvedVars of the instance, it is created on first use
+	resolved unsafe.Pointer
+	// delegate is consulted before the parent by a scope wrapping an instance of an iterator
+	delegate *Instance
+}
+
+// resolvedVars memoises variables computed by the resolver of an instance
+type resolvedVars struct {
+	mu   sync.Mutex
+	vars map[string]*resolvedVar
 }
 
-// Iterator abstracts iteration over a set of instances for expression evaluation
+// resolvedVar is the outcome of resolving a variable, computed once by the first caller while
+// concurrent callers wait for it
+type resolvedVar struct {
+	once  sync.Once
+	value interface{}
+	ok    bool
+	err   error
+}
+
+// Var returns the value of a variable defined in Vars or computed by the resolver of the instance,
+// falling back to the parent scope
+func (i *Instance) Var(name string) (value interface{}, ok bool, err error) {
+	i.lookup(func(scope *Instance) bool {
+		value, ok, err = scope.localVar(name)
+		return err != nil || ok
+	})
+	return value, ok, err
+}
+
+// lookup calls fn for the instance and its enclosing scopes in turn until fn returns true
+func (i *Instance) lookup(fn func(scope *Instance) bool) bool {
+	for scope := i; scope != nil; scope = scope.Parent {
+		if scope.delegate != nil {
+			if scope.delegate.lookup(fn) {
+				return true
+			}
+			continue
+		}
+		if fn(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// localVar returns the value of a variable defined in Vars or computed by the resolver of the instance
+func (i *Instance) localVar(name string) (interface{}, bool, error) {
+	if value, ok := i.Vars[name]; ok {
+		return value, true, nil
+	}
+	if i.Resolver == nil {
+		return nil, false, nil
+	}
+
+	resolved := i.resolvedVars()
+	resolved.mu.Lock()
+	v, ok := resolved.vars[name]
+	if !ok {
+		v = &resolvedVar{}
+		resolved.vars[name] = v
+	}
+	resolved.mu.Unlock()
+
+	v.once.Do(func() {
+		v.value, v.ok, v.err = i.Resolver.ResolveVariable(i, name)
+	})
+	return v.value, v.ok, v.err
+}
+
+// ResetResolved discards variables and errors memoised from the resolver of the instance, so that
+// they're resolved again by following evaluations. Long-lived instances reset them between
+// evaluations to pick up changes, while evaluations in progress keep the values they resolved.
+func (i *Instance) ResetResolved() {
+	atomic.StorePointer(&i.resolved, nil)
+}
+
+// resolvedVars returns memoised variables of the instance, creating them on first use
+func (i *Instance) resolvedVars() *resolvedVars {
+	if p := atomic.LoadPointer(&i.resolved); p != nil {
+		return (*resolvedVars)(p)
+	}
+	atomic.CompareAndSwapPointer(&i.resolved, nil, unsafe.Pointer(&resolvedVars{vars: map[string]*resolvedVar{}}))
+	return (*resolvedVars)(atomic.LoadPointer(&i.resolved))
+}
+
+// Function returns a function defined by the instance or its parent scope
+func (i *Instance) Function(name string) (fn Function, ok bool) {
+	i.lookup(func(scope *Instance) bool {
+		fn, ok = scope.Functions[name]
+		return ok
+	})
+	return fn, ok
+}
+
+// Now returns the current time according to the clock of the instance
+func (i *Instance) Now() time.Time {
+	var clock func() time.Time
+	if !i.lookup(func(scope *Instance) bool {
+		clock = scope.Clock
+		return clock != nil
+	}) {
+		return time.Now()
+	}
+	return clock()
+}
+
+// collator returns the collator of the instance or its parent scope
+func (i *Instance) collator() (collator Collator) {
+	i.lookup(func(scope *Instance) bool {
+		collator = scope.Collator
+		return collator != nil
+	})
+	return collator
+}
+
+// Iterator abstracts iteration over a set of instances for expression evaluation. Next advances
+// the iterator past the instance even when it fails to produce it.
 type Iterator interface {
 	Next() (*Instance, error)
 	Done() bool
@@ -39,14 +203,100 @@ type Iterator interface {
 type InstanceResult struct {
 	Instance *Instance
 	Passed   bool
+	// Errors for instances excluded from the result when using CollectErrors policy
+	Errors []*InstanceError
+}
+
+// InstanceError captures an error produced by an iterator or evaluation of an expression for an instance
+type InstanceError struct {
+	// Index of the instance in iteration order
+	Index int
+	// Instance for which evaluation failed, nil if the iterator failed to produce the instance
+	Instance *Instance
+	Err      error
+}
+
+func (e *InstanceError) Error() string {
+	return e.Err.Error()
+}
+
+// ErrorPolicy defines how errors for individual instances are handled during iteration.
+// Iterators must advance past instances they fail to produce, since iteration continues after
+// errors that are skipped or collected.
+type ErrorPolicy int
+
+const (
+	// AbortOnError stops evaluation returning the first error
+	AbortOnError ErrorPolicy = iota
+	// SkipErrors excludes instances failing evaluation from the result
+	SkipErrors
+	// CollectErrors excludes instances failing evaluation from the result and reports their errors in InstanceResult
+	CollectErrors
+)
+
+// IterableOptions configures evaluation of an iterable expression
+type IterableOptions struct {
+	// Workers is the number of goroutines evaluating instances concurrently.
+	// Instances are evaluated sequentially when Workers is less than 2.
+	Workers int
+	// ErrorPolicy defines how errors for individual instances are handled
+	ErrorPolicy ErrorPolicy
+	// MaxIteratorErrors aborts evaluation regardless of the error policy when the iterator fails
+	// to produce that many instances in a row. Iterator errors are not limited when it is zero.
+	MaxIteratorErrors int
+}
+
+// iteratorErrors counts errors an iterator fails with in a row
+type iteratorErrors struct {
+	max   int
+	count int
 }
 
-// Evaluate evaluates an iterable expression for an iterator
+// check records the outcome of producing an instance, returning an error once the iterator
+// failed the maximum number of times in a row
+func (e *iteratorErrors) check(err error) error {
+	if err == nil {
+		e.count = 0
+		return nil
+	}
+	e.count++
+	if e.max > 0 && e.count >= e.max {
+		return fmt.Errorf("iterator failed %d times in a row: %s", e.count, err)
+	}
+	return nil
+}
+
+// handleError applies error policy to an error for an instance, returning the error if evaluation must be aborted
+func (o IterableOptions) handleError(errs *[]*InstanceError, index int, instance *Instance, err error) error {
+	switch o.ErrorPolicy {
+	case SkipErrors:
+		return nil
+	case CollectErrors:
+		*errs = append(*errs, &InstanceError{
+			Index:    index,
+			Instance: instance,
+			Err:      err,
+		})
+		return nil
+	default:
+		return err
+	}
+}
+
+// Evaluate evaluates an iterable expression for an iterator. Instances without a parent
+// inherit variables and functions of the global instance while they are evaluated.
 func (e *IterableExpression) Evaluate(it Iterator, global *Instance) (*InstanceResult, error) {
+	return e.EvaluateWithOptions(it, global, IterableOptions{})
+}
+
+// EvaluateWithOptions evaluates an iterable expression for an iterator using the provided options
+func (e *IterableExpression) EvaluateWithOptions(it Iterator, global *Instance, options IterableOptions) (*InstanceResult, error) {
 	if e.IterableComparison == nil {
 		return e.iterate(
 			it,
+			global,
 			e.Expression,
+			options,
 			func(instance *Instance, passed bool) bool {
 				// First failure stops the iteration
 				return !passed
@@ -60,11 +310,19 @@ func (e *IterableExpression) Evaluate(it Iterator, global *Instance) (*InstanceR
 
 	fn := *e.IterableComparison.Fn
 
+	switch fn {
+	case "distinct", "unique", "groupBy":
+		return e.evaluateGroups(it, global, options)
+	}
+
 	totalCount := 0
 	passedCount := 0
 	result, err := e.iterate(
 		it,
-		e.IterableComparison.Expression, func(instance *Instance, passed bool) bool {
+		global,
+		e.IterableComparison.Expression,
+		options,
+		func(instance *Instance, passed bool) bool {
 			totalCount++
 			if passed {
 				passedCount++
@@ -89,6 +347,7 @@ func (e *IterableExpression) Evaluate(it Iterator, global *Instance) (*InstanceR
 	return &InstanceResult{
 		Instance: result.Instance,
 		Passed:   passed,
+		Errors:   result.Errors,
 	}, nil
 }
 
@@ -104,60 +363,190 @@ func (e *IterableExpression) evaluatePassed(global *Instance, passedCount, total
 		return passedCount != 0, nil
 
 	case "len":
-		if e.IterableComparison.ScalarComparison == nil {
-			return false, lexer.Errorf(e.Pos, "expecting rhs of iterable comparison using len()")
+		op, expectedCount, err := e.expectedCount(global)
+		if err != nil {
+			return false, err
 		}
+		return intCompare(op, int64(passedCount), expectedCount, e.Pos)
+	default:
+		return false, lexer.Errorf(e.Pos, `unexpected function "%s()" for iterable comparison`, *e.IterableComparison.Fn)
+	}
+}
 
-		if e.IterableComparison.ScalarComparison.Op == nil {
-			return false, lexer.Errorf(e.Pos, "expecting operator for iterable comparison using len()")
+// evaluateGroups evaluates iterable comparisons grouping instances by the value of a key expression:
+//   - distinct(key) compares the number of distinct keys
+//   - groupBy(key) compares the number of instances sharing each key
+//   - unique(key) passes when no two instances share a key
+func (e *IterableExpression) evaluateGroups(it Iterator, global *Instance, options IterableOptions) (*InstanceResult, error) {
+	fn := *e.IterableComparison.Fn
+
+	groups := make(map[interface{}]int64)
+	instance, errs, err := e.visit(
+		it,
+		global,
+		e.IterableComparison.Expression,
+		options,
+		func(instance *Instance, value interface{}) (bool, error) {
+			switch value.(type) {
+			case string, int64, uint64, bool:
+			default:
+				return false, lexer.Errorf(e.Pos, "expecting a string, integer or boolean key for iterable comparison using %s()", fn)
+			}
+			groups[value]++
+			// First duplicate key stops the iteration for unique()
+			return fn != "unique" || groups[value] == 1, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	passed, err := e.evaluateGroupsPassed(global, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InstanceResult{
+		Instance: instance,
+		Passed:   passed,
+		Errors:   errs,
+	}, nil
+}
+
+func (e *IterableExpression) evaluateGroupsPassed(global *Instance, groups map[interface{}]int64) (bool, error) {
+	switch *e.IterableComparison.Fn {
+	case "unique":
+		for _, size := range groups {
+			if size > 1 {
+				return false, nil
+			}
 		}
+		return true, nil
 
-		rhs, err := e.IterableComparison.ScalarComparison.Next.Evaluate(global)
+	case "distinct":
+		op, expectedCount, err := e.expectedCount(global)
 		if err != nil {
 			return false, err
 		}
+		return intCompare(op, int64(len(groups)), expectedCount, e.Pos)
 
-		expectedCount, ok := rhs.(int64)
-		if !ok {
-			return false, lexer.Errorf(e.Pos, "expecting an integer rhs for iterable comparison using len()")
+	case "groupBy":
+		op, expectedCount, err := e.expectedCount(global)
+		if err != nil {
+			return false, err
 		}
+		for _, size := range groups {
+			passed, err := intCompare(op, size, expectedCount, e.Pos)
+			if err != nil || !passed {
+				return false, err
+			}
+		}
+		return true, nil
 
-		return intCompare(*e.IterableComparison.ScalarComparison.Op, int64(passedCount), expectedCount, e.Pos)
 	default:
 		return false, lexer.Errorf(e.Pos, `unexpected function "%s()" for iterable comparison`, *e.IterableComparison.Fn)
 	}
 }
 
-func (e *IterableExpression) iterate(it Iterator, expression *Expression, checkResult func(instance *Instance, passed bool) bool) (*InstanceResult, error) {
+// expectedCount evaluates the operator and integer rhs of an iterable comparison for a global instance
+func (e *IterableExpression) expectedCount(global *Instance) (string, int64, error) {
+	fn := *e.IterableComparison.Fn
+
+	if e.IterableComparison.ScalarComparison == nil {
+		return "", 0, lexer.Errorf(e.Pos, "expecting rhs of iterable comparison using %s()", fn)
+	}
+
+	if e.IterableComparison.ScalarComparison.Op == nil {
+		return "", 0, lexer.Errorf(e.Pos, "expecting operator for iterable comparison using %s()", fn)
+	}
+
+	rhs, err := e.IterableComparison.ScalarComparison.Next.Evaluate(global)
+	if err != nil {
+		return "", 0, err
+	}
+
+	expectedCount, ok := rhs.(int64)
+	if !ok {
+		return "", 0, lexer.Errorf(e.Pos, "expecting an integer rhs for iterable comparison using %s()", fn)
+	}
+
+	return *e.IterableComparison.ScalarComparison.Op, expectedCount, nil
+}
+
+// visitFunc is called with the value of an expression evaluated for an instance and returns false to stop the iteration
+type visitFunc func(instance *Instance, value interface{}) (bool, error)
+
+func (e *IterableExpression) iterate(it Iterator, global *Instance, expression *Expression, options IterableOptions, checkResult func(instance *Instance, passed bool) bool) (*InstanceResult, error) {
+	result := &InstanceResult{}
+	instance, errs, err := e.visit(it, global, expression, options, func(instance *Instance, value interface{}) (bool, error) {
+		passed, ok := value.(bool)
+		if !ok {
+			return false, lexer.Errorf(e.Pos, "expected a boolean resuls of evaluation")
+		}
+		result.Passed = passed
+		return checkResult(instance, passed), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Instance = instance
+	result.Errors = errs
+	return result, nil
+}
+
+// visit evaluates expression for instances of an iterator calling visit with the results in iteration order
+// and returns the last visited instance along with errors collected according to the error policy
+func (e *IterableExpression) visit(it Iterator, global *Instance, expression *Expression, options IterableOptions, visit visitFunc) (*Instance, []*InstanceError, error) {
+	if options.Workers > 1 {
+		return visitParallel(it, global, expression, options, visit)
+	}
+
 	var (
-		instance *Instance
-		err      error
-		passed   bool
+		last  *Instance
+		errs  []*InstanceError
+		guard = iteratorErrors{max: options.MaxIteratorErrors}
 	)
-	for !it.Done() {
-		instance, err = it.Next()
-		if err != nil {
-			return nil, err
+	for index := 0; !it.Done(); index++ {
+		instance, err := it.Next()
+		if err := guard.check(err); err != nil {
+			return nil, nil, err
 		}
 
-		v, err := expression.Evaluate(instance)
-		if err != nil {
-			return nil, err
+		var proceed bool
+		if err == nil {
+			proceed, err = visitInstance(expression, instance, global, visit)
 		}
-
-		var ok bool
-		if passed, ok = v.(bool); !ok {
-			return nil, lexer.Errorf(e.Pos, "expected a boolean resuls of evaluation")
+		if err != nil {
+			if err = options.handleError(&errs, index, instance, err); err != nil {
+				return nil, nil, err
+			}
+			continue
 		}
 
-		if !checkResult(instance, passed) {
+		last = instance
+		if !proceed {
 			break
 		}
 	}
-	return &InstanceResult{
-		Instance: instance,
-		Passed:   passed,
-	}, nil
+	return last, errs, nil
+}
+
+func visitInstance(expression *Expression, instance, global *Instance, visit visitFunc) (bool, error) {
+	value, err := evaluateInScope(expression, instance, global)
+	if err != nil {
+		return false, err
+	}
+	return visit(instance, value)
+}
+
+// evaluateInScope evaluates an expression for an instance of an iterator. An instance without a
+// parent inherits variables and functions of the global instance through a scope wrapping it, so
+// that instances shared by concurrent evaluations are never modified.
+func evaluateInScope(expression *Expression, instance, global *Instance) (interface{}, error) {
+	if instance.Parent == nil && global != nil && instance != global {
+		return expression.Evaluate(&Instance{delegate: instance, Parent: global})
+	}
+	return expression.Evaluate(instance)
 }
 
 func (e *PathExpression) Evaluate(instance *Instance) (interface{}, error) {
@@ -168,6 +557,10 @@ func (e *PathExpression) Evaluate(instance *Instance) (interface{}, error) {
 }
 
 func (e *Expression) Evaluate(instance *Instance) (interface{}, error) {
+	if e.Let != nil {
+		return e.Let.Evaluate(instance)
+	}
+
 	lhs, err := e.Comparison.Evaluate(instance)
 	if err != nil {
 		return nil, err
@@ -198,8 +591,31 @@ func (e *Expression) Evaluate(instance *Instance) (interface{}, error) {
 	return nil, lexer.Errorf(e.Pos, "unsupported operator %s in boolean expression", *e.Op)
 }
 
+func (l *Let) Evaluate(instance *Instance) (interface{}, error) {
+	scope := &Instance{
+		Vars:   make(VarMap, len(l.Bindings)),
+		Parent: instance,
+	}
+	for _, binding := range l.Bindings {
+		value, err := binding.Value.Evaluate(scope)
+		if err != nil {
+			return nil, err
+		}
+		scope.Vars[binding.Name] = value
+	}
+	return l.Body.Evaluate(scope)
+}
+
 func (c *Comparison) Evaluate(instance *Instance) (interface{}, error) {
-	lhs, err := c.Term.Evaluate(instance)
+	var (
+		lhs interface{}
+		err error
+	)
+	if c.Term != nil {
+		lhs, err = c.Term.Evaluate(instance)
+	} else {
+		lhs, err = evaluateValues(c.Values, instance)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -214,6 +630,11 @@ func (c *Comparison) Evaluate(instance *Instance) (interface{}, error) {
 			return nil, err
 		}
 
+		// A network is treated as an array of its addresses
+		if network, ok := rhs.(*net.IPNet); ok {
+			rhs = []interface{}{network}
+		}
+
 		array, ok := rhs.([]interface{})
 		if !ok {
 			return nil, lexer.Errorf(c.Pos, "rhs of %s array operation must be an array", *c.ArrayComparison.Op)
@@ -224,6 +645,12 @@ func (c *Comparison) Evaluate(instance *Instance) (interface{}, error) {
 			return inArray(lhs, array), nil
 		case "notin":
 			return notInArray(lhs, array), nil
+		case "anyof":
+			return anyOfArray(arrayValue(lhs), array), nil
+		case "allof", "superset":
+			return subsetOfArray(array, arrayValue(lhs)), nil
+		case "subset":
+			return subsetOfArray(arrayValue(lhs), array), nil
 		default:
 			return nil, lexer.Errorf(c.Pos, "unsupported array operation %s", *c.ArrayComparison.Op)
 		}
@@ -236,14 +663,40 @@ func (c *Comparison) Evaluate(instance *Instance) (interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
-		return c.compare(lhs, rhs, *c.ScalarComparison.Op)
+		return c.compare(instance, lhs, rhs, *c.ScalarComparison.Op)
 
 	default:
 		return lhs, nil
 	}
 }
 
-func (c *Comparison) compare(lhs, rhs interface{}, op string) (interface{}, error) {
+func (c *Comparison) compare(instance *Instance, lhs, rhs interface{}, op string) (interface{}, error) {
+	switch op {
+	case "contains":
+		return c.contains(lhs, rhs, op)
+	case "notcontains":
+		result, err := c.contains(lhs, rhs, op)
+		if err != nil {
+			return nil, err
+		}
+		return !result, nil
+	}
+
+	if value, ok := lhs.(Comparable); ok {
+		result, err := value.Compare(rhs)
+		if err != nil {
+			return nil, lexer.Errorf(c.Pos, "invalid rhs of %s: %s", op, err)
+		}
+		return orderedCompare(op, result, c.Pos)
+	}
+	if value, ok := rhs.(Comparable); ok {
+		result, err := value.Compare(lhs)
+		if err != nil {
+			return nil, lexer.Errorf(c.Pos, "invalid lhs of %s: %s", op, err)
+		}
+		return orderedCompare(op, -result, c.Pos)
+	}
+
 	switch lhs := lhs.(type) {
 	case uint64:
 		switch rhs := rhs.(type) {
@@ -264,39 +717,117 @@ func (c *Comparison) compare(lhs, rhs interface{}, op string) (interface{}, erro
 			return nil, lexer.Errorf(c.Pos, "rhs of %s must be an integer", op)
 		}
 	case string:
+		if ip, ok := rhs.(net.IP); ok {
+			// Compare as IP addresses when the lhs is the string representation of one
+			if lhs, ok := ipValue(lhs); ok {
+				return orderedCompare(op, bytes.Compare(lhs.To16(), ip.To16()), c.Pos)
+			}
+			return nil, lexer.Errorf(c.Pos, "lhs of %s must be an IP address", op)
+		}
 		rhs, ok := rhs.(string)
 		if !ok {
 			return nil, lexer.Errorf(c.Pos, "rhs of %s must be a string", op)
 		}
+		switch op {
+		case "<", ">", "<=", ">=":
+			if collator := instance.collator(); collator != nil {
+				return orderedCompare(op, collator.CompareString(lhs, rhs), c.Pos)
+			}
+		}
 		return stringCompare(op, lhs, rhs, c.Pos)
+	case time.Time:
+		rhs, ok := rhs.(time.Time)
+		if !ok {
+			return nil, lexer.Errorf(c.Pos, "rhs of %s must be a timestamp", op)
+		}
+		return orderedCompare(op, compareTimes(lhs, rhs), c.Pos)
+	case time.Duration:
+		rhs, ok := rhs.(time.Duration)
+		if !ok {
+			return nil, lexer.Errorf(c.Pos, "rhs of %s must be a duration", op)
+		}
+		return intCompare(op, int64(lhs), int64(rhs), c.Pos)
+	case net.IP:
+		rhs, ok := ipValue(rhs)
+		if !ok {
+			return nil, lexer.Errorf(c.Pos, "rhs of %s must be an IP address", op)
+		}
+		return orderedCompare(op, bytes.Compare(lhs.To16(), rhs.To16()), c.Pos)
 	default:
 		return nil, lexer.Errorf(c.Pos, "lhs of %s must be an integer or string", op)
 	}
 }
 
+// contains checks if a string contains a substring, an array contains an element or a network contains an address
+func (c *Comparison) contains(lhs, rhs interface{}, op string) (bool, error) {
+	switch lhs := lhs.(type) {
+	case string:
+		rhs, ok := rhs.(string)
+		if !ok {
+			return false, lexer.Errorf(c.Pos, "rhs of %s must be a string", op)
+		}
+		return strings.Contains(lhs, rhs), nil
+	case []interface{}:
+		return inArray(coerceIntegers(rhs), lhs), nil
+	case *net.IPNet:
+		return arrayElementMatch(rhs, lhs), nil
+	default:
+		return false, lexer.Errorf(c.Pos, "lhs of %s must be a string, an array or a network", op)
+	}
+}
+
 func (t *Term) Evaluate(instance *Instance) (interface{}, error) {
 	lhs, err := t.Unary.Evaluate(instance)
 	if err != nil {
 		return nil, err
 	}
 
-	if t.Op == nil {
+	// Additive operators bind tighter than bit operators and are evaluated left to right, so that
+	// now() - 1h - 30m subtracts both durations, while bit operators group to the right as in
+	// a & (b | c)
+	term := t
+	for ; term.Op != nil && isAdditiveOp(*term.Op); term = term.Next {
+		if term.Next == nil {
+			return nil, lexer.Errorf(term.Pos, "expected rhs in binary bit operation")
+		}
+
+		rhs, err := term.Next.Unary.Evaluate(instance)
+		if err != nil {
+			return nil, err
+		}
+
+		if lhs, err = term.binaryOp(*term.Op, lhs, rhs); err != nil {
+			return nil, err
+		}
+	}
+
+	if term.Op == nil {
 		return lhs, nil
 	}
 
-	if t.Next == nil {
-		return nil, lexer.Errorf(t.Pos, "expected rhs in binary bit operation")
+	if term.Next == nil {
+		return nil, lexer.Errorf(term.Pos, "expected rhs in binary bit operation")
 	}
 
-	rhs, err := t.Next.Evaluate(instance)
+	rhs, err := term.Next.Evaluate(instance)
 	if err != nil {
 		return nil, err
 	}
 
-	op := *t.Op
+	return term.binaryOp(*term.Op, lhs, rhs)
+}
+
+func isAdditiveOp(op string) bool {
+	return op == "+" || op == "-"
+}
 
+func (t *Term) binaryOp(op string, lhs, rhs interface{}) (interface{}, error) {
 	switch lhs := lhs.(type) {
 	case uint64:
+		// Sums and differences of unsigned integers are signed so that they don't wrap around
+		if isAdditiveOp(op) {
+			return t.binaryOp(op, int64(lhs), rhs)
+		}
 		switch rhs := rhs.(type) {
 		case uint64:
 			return uintBinaryOp(op, lhs, rhs, t.Pos)
@@ -321,6 +852,10 @@ func (t *Term) Evaluate(instance *Instance) (interface{}, error) {
 		default:
 			return nil, lexer.Errorf(t.Pos, "rhs of %s must be a string", op)
 		}
+	case time.Time:
+		return timeBinaryOp(op, lhs, rhs, t.Pos)
+	case time.Duration:
+		return durationBinaryOp(op, lhs, rhs, t.Pos)
 	default:
 		return nil, lexer.Errorf(t.Pos, "binary bit operation not supported for this type")
 	}
@@ -353,6 +888,8 @@ func (u *Unary) Evaluate(instance *Instance) (interface{}, error) {
 			return -rhs, nil
 		case uint64:
 			return -int64(rhs), nil
+		case time.Duration:
+			return -rhs, nil
 		default:
 			return nil, lexer.Errorf(u.Pos, "rhs of %s must be an integer", *u.Op)
 		}
@@ -380,13 +917,14 @@ func (v *Value) Evaluate(instance *Instance) (interface{}, error) {
 		return *v.Decimal, nil
 	case v.String != nil:
 		return *v.String, nil
+	case v.Size != nil:
+		return int64(*v.Size), nil
+	case v.Duration != nil:
+		return time.Duration(*v.Duration), nil
 	case v.Variable != nil:
-		var (
-			ok    bool
-			value interface{}
-		)
-		if instance.Vars != nil {
-			value, ok = instance.Vars[*v.Variable]
+		value, ok, err := instance.Var(*v.Variable)
+		if err != nil {
+			return nil, lexer.Errorf(v.Pos, `failed to resolve variable "%s": %s`, *v.Variable, err)
 		}
 		if !ok {
 			return nil, lexer.Errorf(v.Pos, `unknown variable "%s"`, *v.Variable)
@@ -402,15 +940,26 @@ func (v *Value) Evaluate(instance *Instance) (interface{}, error) {
 }
 
 func (a *Array) Evaluate(instance *Instance) (interface{}, error) {
+	if a.Call != nil {
+		return a.Call.Evaluate(instance)
+	}
 	if a.Ident != nil {
-		value, ok := instance.Vars[*a.Ident]
+		value, ok, err := instance.Var(*a.Ident)
+		if err != nil {
+			return nil, lexer.Errorf(a.Pos, `failed to resolve variable "%s": %s`, *a.Ident, err)
+		}
 		if !ok {
 			return nil, lexer.Errorf(a.Pos, `unknown variable "%s" used as array`, *a.Ident)
 		}
 		return value, nil
 	}
+	return evaluateValues(a.Values, instance)
+}
+
+// evaluateValues evaluates values of an array literal
+func evaluateValues(values []Value, instance *Instance) (interface{}, error) {
 	var result []interface{}
-	for _, value := range a.Values {
+	for _, value := range values {
 		v, err := value.Evaluate(instance)
 		if err != nil {
 			return nil, err
@@ -421,13 +970,7 @@ func (a *Array) Evaluate(instance *Instance) (interface{}, error) {
 }
 
 func (c *Call) Evaluate(instance *Instance) (interface{}, error) {
-	var (
-		fn Function
-		ok bool
-	)
-	if instance.Functions != nil {
-		fn, ok = instance.Functions[c.Name]
-	}
+	fn, ok := instance.Function(c.Name)
 	if !ok {
 		return nil, lexer.Errorf(c.Pos, `unknown function "%s()"`, c.Name)
 	}
@@ -442,7 +985,7 @@ func (c *Call) Evaluate(instance *Instance) (interface{}, error) {
 
 	value, err := fn(instance, args...)
 	if err != nil {
-		return nil, lexer.Errorf(c.Pos, `call to "%s()" failed`, c.Name)
+		return nil, lexer.Errorf(c.Pos, `call to "%s()" failed: %s`, c.Name, err)
 	}
 
 	return coerceIntegers(value), nil
@@ -2,7 +2,13 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/alecthomas/participle/lexer"
 	assert "github.com/stretchr/testify/require"
@@ -13,6 +19,9 @@ type instanceTest struct {
 	expression   string
 	vars         VarMap
 	functions    FunctionMap
+	clock        func() time.Time
+	collator     Collator
+	resolver     VariableResolver
 	expectResult interface{}
 	expectError  error
 }
@@ -27,6 +36,9 @@ func (test instanceTest) Run(t *testing.T) {
 	instance := &Instance{
 		Functions: test.functions,
 		Vars:      test.vars,
+		Clock:     test.clock,
+		Collator:  test.collator,
+		Resolver:  test.resolver,
 	}
 	result, err := expr.Evaluate(instance)
 	if test.expectError != nil {
@@ -85,7 +97,7 @@ func TestEvalFunction(t *testing.T) {
 					return nil, errors.New("hey failed")
 				},
 			},
-			expectError: newLexerError(0, `call to "hey()" failed`),
+			expectError: newLexerError(0, `call to "hey()" failed: hey failed`),
 		},
 		{
 			name:       "function arg evaluation error",
@@ -306,6 +318,31 @@ func TestEvalBitOperations(t *testing.T) {
 			expression:   "0x0101 ^ 0x1010",
 			expectResult: uint64(0x1111),
 		},
+		{
+			name:         "chained bitwise operations group to the right",
+			expression:   "0x0f & 0x03 | 0x10",
+			expectResult: uint64(0x03),
+		},
+		{
+			name:         "integer addition and subtraction",
+			expression:   "5-2 + 10 - 1",
+			expectResult: int64(12),
+		},
+		{
+			name:         "unsigned subtraction is signed",
+			expression:   "0x1 - 2",
+			expectResult: int64(-1),
+		},
+		{
+			name:         "additive operators bind tighter than bit operators",
+			expression:   "1 + 2 & 0x6 | 1 - 1",
+			expectResult: int64(2),
+		},
+		{
+			name:         "signed literals in array",
+			expression:   "-5 in [+3, -5]",
+			expectResult: true,
+		},
 		{
 			name:         "unsigned unary bitwise not",
 			expression:   "^0x0",
@@ -460,6 +497,31 @@ func TestEvalString(t *testing.T) {
 			expression:   `"def" !~ "^a.+$"`,
 			expectResult: true,
 		},
+		{
+			name:         "string like",
+			expression:   `"/etc/passwd" like "/etc/%"`,
+			expectResult: true,
+		},
+		{
+			name:         "string not ilike",
+			expression:   `"/ETC/passwd" not ilike "/etc/%"`,
+			expectResult: false,
+		},
+		{
+			name:         "string glob",
+			expression:   `"/etc/ssh/sshd_config" glob "/etc/ssh/*_config"`,
+			expectResult: true,
+		},
+		{
+			name:         "string not iglob",
+			expression:   `"/ETC/hosts" not iglob "/etc/*"`,
+			expectResult: false,
+		},
+		{
+			name:         "string not in array",
+			expression:   `"a" not in ["b", "c"]`,
+			expectResult: true,
+		},
 		{
 			name:         "string concat",
 			expression:   `"abc" + "def"`,
@@ -478,6 +540,75 @@ func TestEvalString(t *testing.T) {
 	}.Run(t)
 }
 
+// foldingCollator orders strings ignoring case, breaking ties byte-wise
+type foldingCollator struct{}
+
+func (foldingCollator) CompareString(a, b string) int {
+	if result := strings.Compare(strings.ToLower(a), strings.ToLower(b)); result != 0 {
+		return result
+	}
+	return strings.Compare(a, b)
+}
+
+func TestEvalStringCaseInsensitive(t *testing.T) {
+	instanceTests{
+		{
+			name:         "equal ignoring case",
+			expression:   `"Root" ==~ "root"`,
+			expectResult: true,
+		},
+		{
+			name:         "not equal ignoring case",
+			expression:   `"Root" !=~ "root"`,
+			expectResult: false,
+		},
+		{
+			name:         "equal ignoring case of unicode",
+			expression:   `"ÉCOLE" ==~ "école"`,
+			expectResult: true,
+		},
+		{
+			name:         "equal ignoring case of final sigma",
+			expression:   `"ΟΔΟΣ" ==~ "οδος"`,
+			expectResult: true,
+		},
+		{
+			name:         "equal ignoring case of variable",
+			expression:   `file.owner ==~ "ROOT"`,
+			vars:         VarMap{"file.owner": "root"},
+			expectResult: true,
+		},
+		{
+			name:        "equal ignoring case of integers",
+			expression:  `1 ==~ 1`,
+			expectError: newLexerError(0, "unsupported operator ==~ for integer comparison"),
+		},
+		{
+			name:         "byte-wise ordering",
+			expression:   `"apple" < "Banana"`,
+			expectResult: false,
+		},
+		{
+			name:         "collated ordering",
+			expression:   `"apple" < "Banana"`,
+			collator:     foldingCollator{},
+			expectResult: true,
+		},
+		{
+			name:         "collated ordering of equal strings",
+			expression:   `"Banana" >= "banana"`,
+			collator:     foldingCollator{},
+			expectResult: false,
+		},
+		{
+			name:         "collator is not used for equality",
+			expression:   `"Banana" == "banana"`,
+			collator:     foldingCollator{},
+			expectResult: false,
+		},
+	}.Run(t)
+}
+
 func TestEvalArrayOperations(t *testing.T) {
 	instanceTests{
 		{
@@ -538,6 +669,149 @@ func TestEvalArrayOperations(t *testing.T) {
 			},
 			expectError: newLexerError(0, "rhs of in array operation must be an array"),
 		},
+		{
+			name:         "contains - string - true",
+			expression:   `"/etc/passwd" contains "etc"`,
+			expectResult: true,
+		},
+		{
+			name:         "not contains - string - true",
+			expression:   `"/etc/passwd" not contains "shadow"`,
+			expectResult: true,
+		},
+		{
+			name:       "contains - var array - true",
+			expression: `mount.options contains "nodev"`,
+			vars: VarMap{
+				"mount.options": []interface{}{"rw", "nodev", "nosuid", "noexec"},
+			},
+			expectResult: true,
+		},
+		{
+			name:       "not contains - var array - false",
+			expression: `mount.options not contains "nodev"`,
+			vars: VarMap{
+				"mount.options": []interface{}{"rw", "nodev", "nosuid", "noexec"},
+			},
+			expectResult: false,
+		},
+		{
+			name:       "contains - integer array - true",
+			expression: `ports contains 22`,
+			vars: VarMap{
+				"ports": []interface{}{22, 80, 443},
+			},
+			expectResult: true,
+		},
+		{
+			name:       "any of - var array - true",
+			expression: `mount.options any of ["nodev", "ro"]`,
+			vars: VarMap{
+				"mount.options": []interface{}{"rw", "nodev", "nosuid", "noexec"},
+			},
+			expectResult: true,
+		},
+		{
+			name:       "any of - var array - false",
+			expression: `mount.options any of ["ro", "sync"]`,
+			vars: VarMap{
+				"mount.options": []interface{}{"rw", "nodev", "nosuid", "noexec"},
+			},
+			expectResult: false,
+		},
+		{
+			name:         "any of - scalar - true",
+			expression:   `"ro" any of ["rw", "ro"]`,
+			expectResult: true,
+		},
+		{
+			name:       "all of - var array - true",
+			expression: `mount.options all of ["nodev", "nosuid"]`,
+			vars: VarMap{
+				"mount.options": []interface{}{"rw", "nodev", "nosuid", "noexec"},
+			},
+			expectResult: true,
+		},
+		{
+			name:       "all of - var array - false",
+			expression: `mount.options all of ["nodev", "sync"]`,
+			vars: VarMap{
+				"mount.options": []interface{}{"rw", "nodev", "nosuid", "noexec"},
+			},
+			expectResult: false,
+		},
+		{
+			name:       "superset - var array - true",
+			expression: `mount.options superset ["rw", "noexec"]`,
+			vars: VarMap{
+				"mount.options": []interface{}{"rw", "nodev", "nosuid", "noexec"},
+			},
+			expectResult: true,
+		},
+		{
+			name:       "subset - var array - false",
+			expression: `mount.options subset ["rw", "nodev"]`,
+			vars: VarMap{
+				"mount.options": []interface{}{"rw", "nodev", "nosuid", "noexec"},
+			},
+			expectResult: false,
+		},
+		{
+			name:       "subset - string array - true",
+			expression: `mount.options subset ["rw", "nodev", "nosuid", "noexec", "relatime"]`,
+			vars: VarMap{
+				"mount.options": []interface{}{"rw", "nodev", "nosuid", "noexec"},
+			},
+			expectResult: true,
+		},
+		{
+			name:         "array literal lhs - any of",
+			expression:   `[1, 2] any of [2, 3] && !([1, 2] any of [3, 4])`,
+			expectResult: true,
+		},
+		{
+			name:       "array literal lhs - subset and all of",
+			expression: `["rw", opt] subset mount.options && [opt, "rw", "nodev"] all of mount.options`,
+			vars: VarMap{
+				"mount.options": []interface{}{"rw", "nodev"},
+				"opt":           "nodev",
+			},
+			expectResult: true,
+		},
+		{
+			name:         "array literal lhs - in",
+			expression:   `[1] not in [1, 2]`,
+			expectResult: true,
+		},
+		{
+			name:         "array literal",
+			expression:   `[1, "a"]`,
+			expectResult: []interface{}{int64(1), "a"},
+		},
+		{
+			name:        "array literal lhs of scalar comparison",
+			expression:  `[1] == 1`,
+			expectError: newLexerError(0, "lhs of == must be an integer or string"),
+		},
+		{
+			name:        "invalid lhs of contains",
+			expression:  `1 contains 1`,
+			expectError: newLexerError(0, "lhs of contains must be a string, an array or a network"),
+		},
+		{
+			name:        "invalid rhs of contains",
+			expression:  `"abc" contains 1`,
+			expectError: newLexerError(0, "rhs of contains must be a string"),
+		},
+		{
+			name:       "invalid rhs of all of",
+			expression: "mount.options all of notarray",
+			vars: VarMap{
+				"mount.options": []interface{}{"rw"},
+				"notarray":      "rw",
+			},
+			expectError: newLexerError(0, "rhs of allof array operation must be an array"),
+		},
 	}.Run(t)
 }
 
@@ -561,6 +835,239 @@ func TestEvalSubExpression(t *testing.T) {
 	}.Run(t)
 }
 
+func TestEvalLet(t *testing.T) {
+	flag := func(instance *Instance, args ...interface{}) (interface{}, error) {
+		return "/etc/kubernetes/kubelet.yaml", nil
+	}
+
+	instanceTests{
+		{
+			name:         "binding",
+			expression:   `let config = flag("kubelet", "--config") in config != "" && config =~ "^/etc/" && config like "%.yaml"`,
+			functions:    FunctionMap{"flag": flag},
+			expectResult: true,
+		},
+		{
+			name:         "sequential bindings",
+			expression:   `let a = 2, b = a | 1, a = b ^ 4 in a == 7 && b == 3`,
+			expectResult: true,
+		},
+		{
+			name:         "shadowed variable",
+			expression:   `let x = x | 4 in x == 5`,
+			vars:         VarMap{"x": 1},
+			expectResult: true,
+		},
+		{
+			name:         "nested let",
+			expression:   `let x = 1 in (let x = 2 in x == 2) && x == 1`,
+			expectResult: true,
+		},
+		{
+			name:         "let on rhs",
+			expression:   `x == 1 && let x = 2 in x == 2`,
+			vars:         VarMap{"x": 1},
+			expectResult: true,
+		},
+		{
+			name:         "let in term",
+			expression:   `(let x = 2 in x | 1) & 6 == 2`,
+			expectResult: true,
+		},
+		{
+			name:         "parenthesised comparison",
+			expression:   `let ok = (1 == 1) in ok && ok`,
+			expectResult: true,
+		},
+		{
+			name:         "bound array",
+			expression:   `let names = names() in "sshd" in names && "cron" not in names`,
+			functions:    FunctionMap{"names": func(instance *Instance, args ...interface{}) (interface{}, error) { return []interface{}{"sshd"}, nil }},
+			expectResult: true,
+		},
+		{
+			name:         "let as variable name",
+			expression:   `let == 1`,
+			vars:         VarMap{"let": 1},
+			expectResult: true,
+		},
+		{
+			name:        "binding out of scope",
+			expression:  `(let x = 1 in x == 1) && x == 1`,
+			expectError: newLexerError(25, `unknown variable "x"`),
+		},
+		{
+			name:        "failed binding",
+			expression:  `let x = y in x == 1`,
+			expectError: newLexerError(8, `unknown variable "y"`),
+		},
+	}.Run(t)
+}
+
+func TestEvalLetEvaluatesOnce(t *testing.T) {
+	assert := assert.New(t)
+	calls := 0
+	instance := &Instance{
+		Functions: FunctionMap{
+			"flag": func(instance *Instance, args ...interface{}) (interface{}, error) {
+				calls++
+				return "/etc/kubernetes/kubelet.yaml", nil
+			},
+			"dir": func(instance *Instance, args ...interface{}) (interface{}, error) {
+				value, _, err := instance.Var("config")
+				return path.Dir(value.(string)), err
+			},
+		},
+	}
+
+	expr, err := ParseExpression(`let config = flag("kubelet", "--config") in config != "" && config =~ "\\.yaml$" && dir() == "/etc/kubernetes"`)
+	assert.NoError(err)
+	result, err := expr.Evaluate(instance)
+	assert.NoError(err)
+	assert.Equal(true, result)
+	assert.Equal(1, calls)
+	assert.Empty(instance.Vars)
+}
+
+func TestEvalVariableResolver(t *testing.T) {
+	resolver := VariableResolverFunc(func(instance *Instance, name string) (interface{}, bool, error) {
+		switch name {
+		case "file.hash":
+			return "e3b0c442", true, nil
+		case "process.names":
+			return []interface{}{"sshd", "cron"}, true, nil
+		case "file.size":
+			return 1024, true, nil
+		case "broken":
+			return nil, false, errors.New("permission denied")
+		}
+		return nil, false, nil
+	})
+
+	instanceTests{
+		{
+			name:         "resolved variable",
+			expression:   `file.hash == "e3b0c442"`,
+			resolver:     resolver,
+			expectResult: true,
+		},
+		{
+			name:         "resolved integer",
+			expression:   `file.size == 1KiB`,
+			resolver:     resolver,
+			expectResult: true,
+		},
+		{
+			name:         "resolved array",
+			expression:   `"sshd" in process.names`,
+			resolver:     resolver,
+			expectResult: true,
+		},
+		{
+			name:         "vars take precedence",
+			expression:   `file.hash == "da39a3ee"`,
+			vars:         VarMap{"file.hash": "da39a3ee"},
+			resolver:     resolver,
+			expectResult: true,
+		},
+		{
+			name:        "unknown variable",
+			expression:  `file.owner == "root"`,
+			resolver:    resolver,
+			expectError: newLexerError(0, `unknown variable "file.owner"`),
+		},
+		{
+			name:        "unknown array variable",
+			expression:  `"sshd" in processes`,
+			resolver:    resolver,
+			expectError: newLexerError(10, `unknown variable "processes" used as array`),
+		},
+		{
+			name:        "failed to resolve",
+			expression:  `broken == 1`,
+			resolver:    resolver,
+			expectError: newLexerError(0, `failed to resolve variable "broken": permission denied`),
+		},
+		{
+			name:        "failed to resolve array",
+			expression:  `1 in broken`,
+			resolver:    resolver,
+			expectError: newLexerError(5, `failed to resolve variable "broken": permission denied`),
+		},
+	}.Run(t)
+}
+
+func TestEvalVariableResolverMemoisation(t *testing.T) {
+	assert := assert.New(t)
+	calls := map[string]int{}
+	instance := &Instance{
+		Resolver: VariableResolverFunc(func(instance *Instance, name string) (interface{}, bool, error) {
+			calls[name]++
+			switch name {
+			case "a":
+				return 1, true, nil
+			case "broken":
+				return nil, false, errors.New("permission denied")
+			}
+			return nil, false, nil
+		}),
+	}
+
+	expr, err := ParseExpression(`a == 1 && a in [1, 2] && (a > 0 || a < 0)`)
+	assert.NoError(err)
+	result, err := expr.Evaluate(instance)
+	assert.NoError(err)
+	assert.Equal(true, result)
+
+	for i := 0; i < 2; i++ {
+		expr, err = ParseExpression(`b == 1`)
+		assert.NoError(err)
+		_, err = expr.Evaluate(instance)
+		assert.EqualError(err, `1:1: unknown variable "b"`)
+
+		expr, err = ParseExpression(`broken == 1`)
+		assert.NoError(err)
+		_, err = expr.Evaluate(instance)
+		assert.EqualError(err, `1:1: failed to resolve variable "broken": permission denied`)
+	}
+	assert.Equal(map[string]int{"a": 1, "b": 1, "broken": 1}, calls)
+
+	instance.ResetResolved()
+	expr, err = ParseExpression(`a == 1 && a == 1`)
+	assert.NoError(err)
+	result, err = expr.Evaluate(instance)
+	assert.NoError(err)
+	assert.Equal(true, result)
+	expr, err = ParseExpression(`broken == 1`)
+	assert.NoError(err)
+	_, err = expr.Evaluate(instance)
+	assert.Error(err)
+	assert.Equal(map[string]int{"a": 2, "b": 1, "broken": 2}, calls)
+}
+
+func TestEvalVariableResolverConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	var calls int32
+	global := &Instance{
+		Resolver: VariableResolverFunc(func(instance *Instance, name string) (interface{}, bool, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(time.Millisecond)
+			return 1, true, nil
+		}),
+	}
+
+	var instances []*Instance
+	for i := 0; i < 100; i++ {
+		instances = append(instances, &Instance{})
+	}
+	expr, err := ParseIterable(`all(a == 1)`)
+	assert.NoError(err)
+	result, err := expr.EvaluateWithOptions(&iteratorMock{instances: instances}, global, IterableOptions{Workers: 8})
+	assert.NoError(err)
+	assert.True(result.Passed)
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
 type iteratorMock struct {
 	instances []*Instance
 	index     int
@@ -687,6 +1194,231 @@ func TestEvalIterable(t *testing.T) {
 	}
 }
 
+func TestEvalInstanceScope(t *testing.T) {
+	global := &Instance{
+		Functions: FunctionMap{
+			"double": func(instance *Instance, args ...interface{}) (interface{}, error) {
+				return args[0].(int64) * 2, nil
+			},
+			"owner": func(instance *Instance, args ...interface{}) (interface{}, error) {
+				return instance.Vars["file.owner"], nil
+			},
+		},
+		Vars: VarMap{
+			"EXPECTED": 2,
+			"OWNER":    "root",
+			"owners":   []interface{}{"root", "daemon"},
+		},
+		Resolver: VariableResolverFunc(func(instance *Instance, name string) (interface{}, bool, error) {
+			if name == "kernel.version" {
+				return "5.4.0", true, nil
+			}
+			return nil, false, nil
+		}),
+	}
+
+	instance := &Instance{
+		Parent: global,
+		Functions: FunctionMap{
+			"owner": func(instance *Instance, args ...interface{}) (interface{}, error) {
+				return "nobody", nil
+			},
+		},
+		Vars: VarMap{
+			"file.owner": "root",
+			"OWNER":      "alice",
+		},
+	}
+
+	tests := []struct {
+		name         string
+		expression   string
+		expectResult interface{}
+		expectError  error
+	}{
+		{
+			name:         "parent variable",
+			expression:   `EXPECTED == 2`,
+			expectResult: true,
+		},
+		{
+			name:         "shadowed variable",
+			expression:   `OWNER == "alice"`,
+			expectResult: true,
+		},
+		{
+			name:         "parent array",
+			expression:   `file.owner in owners`,
+			expectResult: true,
+		},
+		{
+			name:         "parent resolver",
+			expression:   `kernel.version == "5.4.0"`,
+			expectResult: true,
+		},
+		{
+			name:         "parent function",
+			expression:   `double(EXPECTED) == 4`,
+			expectResult: true,
+		},
+		{
+			name:         "shadowed function",
+			expression:   `owner() == "nobody"`,
+			expectResult: true,
+		},
+		{
+			name:        "unknown variable",
+			expression:  `missing == 1`,
+			expectError: newLexerError(0, `unknown variable "missing"`),
+		},
+		{
+			name:        "unknown function",
+			expression:  `missing() == 1`,
+			expectError: newLexerError(0, `unknown function "missing()"`),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			expr, err := ParseExpression(test.expression)
+			assert.NoError(err)
+
+			result, err := expr.Evaluate(instance)
+			if test.expectError != nil {
+				assert.Equal(test.expectError, err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectResult, result)
+			}
+		})
+	}
+}
+
+func TestEvalIterableGlobalScope(t *testing.T) {
+	newInstances := func() []*Instance {
+		return []*Instance{
+			{Vars: VarMap{"file.owner": "root", "file.permissions": 0644}},
+			{Vars: VarMap{"file.owner": "root", "file.permissions": 0600}},
+			{Vars: VarMap{"file.owner": "alice", "file.permissions": 0644}},
+		}
+	}
+	global := &Instance{
+		Functions: FunctionMap{
+			"readable": func(instance *Instance, args ...interface{}) (interface{}, error) {
+				permissions, _, err := instance.Var("file.permissions")
+				return permissions.(int)&04 != 0, err
+			},
+		},
+		Vars: VarMap{
+			"EXPECTED": 2,
+			"OWNER":    "root",
+		},
+	}
+
+	tests := []struct {
+		name         string
+		expression   string
+		workers      int
+		expectResult bool
+	}{
+		{
+			name:         "global variable",
+			expression:   `len(file.owner == OWNER) == EXPECTED`,
+			expectResult: true,
+		},
+		{
+			name:         "global function",
+			expression:   `len(readable()) == EXPECTED`,
+			expectResult: true,
+		},
+		{
+			name:         "global variable in parallel",
+			expression:   `all(file.owner == OWNER || readable())`,
+			workers:      2,
+			expectResult: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			expr, err := ParseIterable(test.expression)
+			assert.NoError(err)
+
+			result, err := expr.EvaluateWithOptions(
+				&iteratorMock{instances: newInstances()},
+				global,
+				IterableOptions{Workers: test.workers},
+			)
+			assert.NoError(err)
+			assert.Equal(test.expectResult, result.Passed)
+		})
+	}
+}
+
+func TestEvalIterableGlobalScopeIsTemporary(t *testing.T) {
+	assert := assert.New(t)
+	instances := []*Instance{
+		{Vars: VarMap{"file.owner": "root"}},
+		{Vars: VarMap{"file.owner": "alice"}},
+	}
+	expr, err := ParseIterable(`len(file.owner == OWNER) == 1`)
+	assert.NoError(err)
+
+	for _, owner := range []string{"root", "alice", "bob"} {
+		for _, workers := range []int{0, 2} {
+			global := &Instance{Vars: VarMap{"OWNER": owner}}
+			result, err := expr.EvaluateWithOptions(&iteratorMock{instances: instances}, global, IterableOptions{Workers: workers})
+			assert.NoError(err)
+			assert.Equal(owner != "bob", result.Passed, owner)
+			for _, instance := range instances {
+				assert.Nil(instance.Parent)
+			}
+		}
+	}
+}
+
+func TestEvalIterableGlobalScopeConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	instances := []*Instance{
+		{Vars: VarMap{"file.owner": "root"}},
+		{Vars: VarMap{"file.owner": "root"}},
+		{Vars: VarMap{"file.owner": "alice"}},
+	}
+	shared := &Instance{Vars: VarMap{"file.owner": "root"}}
+	expr, err := ParseIterable(`len(file.owner == OWNER) == EXPECTED`)
+	assert.NoError(err)
+	all, err := ParseIterable(`all(file.owner == OWNER)`)
+	assert.NoError(err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		owner, expected := "root", 2
+		if i%2 == 1 {
+			owner, expected = "alice", 1
+		}
+		global := &Instance{Vars: VarMap{"OWNER": owner, "EXPECTED": expected}}
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			result, err := expr.Evaluate(&iteratorMock{instances: instances}, global)
+			assert.NoError(err)
+			assert.True(result.Passed, owner)
+		}()
+		go func() {
+			defer wg.Done()
+			// The iterator reuses the same instance for every item
+			reused := &iteratorMock{instances: []*Instance{shared, shared, shared}}
+			result, err := all.EvaluateWithOptions(reused, global, IterableOptions{Workers: 2})
+			assert.NoError(err)
+			assert.Equal(owner == "root", result.Passed, owner)
+		}()
+	}
+	wg.Wait()
+}
+
 func TestEvalPathExpression(t *testing.T) {
 	instance := &Instance{
 		Functions: map[string]Function{
@@ -740,3 +1472,280 @@ func TestEvalPathExpression(t *testing.T) {
 		})
 	}
 }
+
+func TestEvalIterableGroups(t *testing.T) {
+	newUser := func(name string, uid int, shell string) *Instance {
+		return &Instance{
+			Vars: VarMap{
+				"user.name":  name,
+				"user.uid":   uid,
+				"user.shell": shell,
+				"user.roles": []interface{}{"admin"},
+			},
+		}
+	}
+	instances := []*Instance{
+		newUser("root", 0, "/bin/bash"),
+		newUser("daemon", 1, "/usr/sbin/nologin"),
+		newUser("toor", 0, "/bin/sh"),
+		newUser("alice", 1000, "/bin/bash"),
+	}
+
+	tests := []struct {
+		name           string
+		expression     string
+		global         Instance
+		expectResult   bool
+		expectInstance *Instance
+		expectError    error
+	}{
+		{
+			name:           "unique",
+			expression:     `unique(user.name)`,
+			expectResult:   true,
+			expectInstance: instances[3],
+		},
+		{
+			name:           "not unique",
+			expression:     `unique(user.uid)`,
+			expectResult:   false,
+			expectInstance: instances[2],
+		},
+		{
+			name:         "unique boolean key",
+			expression:   `unique(user.uid == 0)`,
+			expectResult: false,
+		},
+		{
+			name:         "distinct",
+			expression:   `distinct(user.shell) == 3`,
+			expectResult: true,
+		},
+		{
+			name:       "distinct global rhs",
+			expression: `distinct(user.uid) < EXPECTED`,
+			global: Instance{
+				Vars: VarMap{
+					"EXPECTED": 3,
+				},
+			},
+			expectResult: false,
+		},
+		{
+			name:         "group by",
+			expression:   `groupBy(user.shell) <= 2`,
+			expectResult: true,
+		},
+		{
+			name:         "group by failed",
+			expression:   `groupBy(user.uid) <= 1`,
+			expectResult: false,
+		},
+		{
+			name:        "group by missing comparison",
+			expression:  `groupBy(user.uid)`,
+			expectError: newLexerError(0, "expecting rhs of iterable comparison using groupBy()"),
+		},
+		{
+			name:        "distinct invalid rhs",
+			expression:  `distinct(user.uid) == "3"`,
+			expectError: newLexerError(0, "expecting an integer rhs for iterable comparison using distinct()"),
+		},
+		{
+			name:        "invalid key",
+			expression:  `unique(user.roles)`,
+			expectError: newLexerError(0, "expecting a string, integer or boolean key for iterable comparison using unique()"),
+		},
+		{
+			name:        "key evaluation error",
+			expression:  `unique(user.home)`,
+			expectError: newLexerError(7, `unknown variable "user.home"`),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			iterator := &iteratorMock{
+				instances: instances,
+			}
+
+			assert := assert.New(t)
+			expr, err := ParseIterable(test.expression)
+			assert.NoError(err)
+			assert.NotNil(expr)
+
+			value, err := expr.Evaluate(iterator, &test.global)
+			if test.expectError != nil {
+				assert.Equal(test.expectError, err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectResult, value.Passed)
+				if test.expectInstance != nil {
+					assert.True(test.expectInstance == value.Instance, "unexpected instance in result")
+				}
+			}
+		})
+	}
+}
+
+func TestEvalIterableErrorPolicy(t *testing.T) {
+	instances := []*Instance{
+		{
+			Vars: VarMap{
+				"file.owner": "root",
+			},
+		},
+		{
+			Vars: VarMap{},
+		},
+		{
+			Vars: VarMap{
+				"file.owner": "root",
+			},
+		},
+		{
+			Vars: VarMap{
+				"file.owner": "alice",
+			},
+		},
+		{
+			Vars: VarMap{
+				"file.owner": "root",
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		expression   string
+		policy       ErrorPolicy
+		expectResult bool
+		expectErrors []*InstanceError
+		expectError  error
+	}{
+		{
+			name:        "abort",
+			expression:  `all(file.owner != "")`,
+			policy:      AbortOnError,
+			expectError: newLexerError(4, `unknown variable "file.owner"`),
+		},
+		{
+			name:         "skip",
+			expression:   `all(file.owner != "")`,
+			policy:       SkipErrors,
+			expectResult: true,
+		},
+		{
+			name:         "collect",
+			expression:   `all(file.owner != "")`,
+			policy:       CollectErrors,
+			expectResult: true,
+			expectErrors: []*InstanceError{
+				{
+					Index:    1,
+					Instance: instances[1],
+					Err:      newLexerError(4, `unknown variable "file.owner"`),
+				},
+				{
+					Index: 2,
+					Err:   errors.New("iterator failed"),
+				},
+			},
+		},
+		{
+			name:         "collect until early exit",
+			expression:   `any(file.owner == "alice")`,
+			policy:       CollectErrors,
+			expectResult: true,
+			expectErrors: []*InstanceError{
+				{
+					Index:    1,
+					Instance: instances[1],
+					Err:      newLexerError(4, `unknown variable "file.owner"`),
+				},
+				{
+					Index: 2,
+					Err:   errors.New("iterator failed"),
+				},
+			},
+		},
+		{
+			name:         "collect excluded from len",
+			expression:   `len(file.owner == "root") == 2`,
+			policy:       CollectErrors,
+			expectResult: true,
+			expectErrors: []*InstanceError{
+				{
+					Index:    1,
+					Instance: instances[1],
+					Err:      newLexerError(4, `unknown variable "file.owner"`),
+				},
+				{
+					Index: 2,
+					Err:   errors.New("iterator failed"),
+				},
+			},
+		},
+		{
+			name:         "collect in groups",
+			expression:   `distinct(file.owner) == 2`,
+			policy:       CollectErrors,
+			expectResult: true,
+			expectErrors: []*InstanceError{
+				{
+					Index:    1,
+					Instance: instances[1],
+					Err:      newLexerError(9, `unknown variable "file.owner"`),
+				},
+				{
+					Index: 2,
+					Err:   errors.New("iterator failed"),
+				},
+			},
+		},
+		{
+			name:         "collect without function",
+			expression:   `file.owner == "alice"`,
+			policy:       CollectErrors,
+			expectResult: true,
+			expectErrors: []*InstanceError{
+				{
+					Index:    1,
+					Instance: instances[1],
+					Err:      newLexerError(0, `unknown variable "file.owner"`),
+				},
+				{
+					Index: 2,
+					Err:   errors.New("iterator failed"),
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		for _, workers := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s with %d workers", test.name, workers), func(t *testing.T) {
+				assert := assert.New(t)
+				expr, err := ParseIterable(test.expression)
+				assert.NoError(err)
+				assert.NotNil(expr)
+
+				it := &countingIterator{
+					iteratorMock: iteratorMock{instances: instances},
+					failAt:       2,
+				}
+				result, err := expr.EvaluateWithOptions(it, &Instance{}, IterableOptions{
+					Workers:     workers,
+					ErrorPolicy: test.policy,
+				})
+				if test.expectError != nil {
+					assert.Equal(test.expectError, err)
+				} else {
+					assert.NoError(err)
+					assert.Equal(test.expectResult, result.Passed)
+					assert.Equal(test.expectErrors, result.Errors)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// FileFunctions returns functions inspecting files of a filesystem:
+//   - exists(path) reports whether a file exists
+//   - stat.mode(path) returns unix permission bits of a file including setuid, setgid and sticky bits
+//   - owner(path) and group(path) return names of the user and group owning a file, looked up in
+//     /etc/passwd and /etc/group of the filesystem, or their numeric ids when not found there
+//   - size(path) returns the size of a file in bytes
+//   - mtime(path) returns the modification time of a file
+//   - readlink(path) returns the destination of a symbolic link
+//   - content(path) returns contents of a file
+//   - sha256(path) returns hex encoded SHA-256 checksum of contents of a file
+//
+// Ownership is read from file info of the filesystem providing *syscall.Stat_t, as DirFS does on
+// unix systems, owner and group fail with an error for other filesystems and systems. The user
+// database of the host is consulted as well for HostFileSystem, covering users not listed in
+// /etc/passwd.
+//
+// The path argument may be omitted to use the "file.path" variable of the instance,
+// such as the ones produced by resolving a PathExpression.
+func FileFunctions(fsys FileSystem) FunctionMap {
+	stat := func(instance *Instance, args []interface{}) (os.FileInfo, error) {
+		name, err := filePathArg(instance, args)
+		if err != nil {
+			return nil, err
+		}
+		return fsys.Stat(name)
+	}
+
+	return FunctionMap{
+		"exists": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			_, err := stat(instance, args)
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return err == nil, err
+		},
+		"stat.mode": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			info, err := stat(instance, args)
+			if err != nil {
+				return nil, err
+			}
+			return fileMode(info.Mode()), nil
+		},
+		"owner": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			info, err := stat(instance, args)
+			if err != nil {
+				return nil, err
+			}
+			uid, _, ok := fileOwnership(info)
+			if !ok {
+				return nil, errors.New("file ownership is not supported by the filesystem")
+			}
+			return lookupID(fsys, "/etc/passwd", uid, func(id string) (string, error) {
+				u, err := user.LookupId(id)
+				if err != nil {
+					return "", err
+				}
+				return u.Username, nil
+			}), nil
+		},
+		"group": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			info, err := stat(instance, args)
+			if err != nil {
+				return nil, err
+			}
+			_, gid, ok := fileOwnership(info)
+			if !ok {
+				return nil, errors.New("file ownership is not supported by the filesystem")
+			}
+			return lookupID(fsys, "/etc/group", gid, func(id string) (string, error) {
+				g, err := user.LookupGroupId(id)
+				if err != nil {
+					return "", err
+				}
+				return g.Name, nil
+			}), nil
+		},
+		"size": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			info, err := stat(instance, args)
+			if err != nil {
+				return nil, err
+			}
+			return info.Size(), nil
+		},
+		"mtime": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			info, err := stat(instance, args)
+			if err != nil {
+				return nil, err
+			}
+			return info.ModTime(), nil
+		},
+		"readlink": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			name, err := filePathArg(instance, args)
+			if err != nil {
+				return nil, err
+			}
+			return fsys.Readlink(name)
+		},
+		"content": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			name, err := filePathArg(instance, args)
+			if err != nil {
+				return nil, err
+			}
+			content, err := fsys.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			return string(content), nil
+		},
+		"sha256": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			name, err := filePathArg(instance, args)
+			if err != nil {
+				return nil, err
+			}
+			content, err := fsys.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(content)
+			return hex.EncodeToString(sum[:]), nil
+		},
+	}
+}
+
+func filePathArg(instance *Instance, args []interface{}) (string, error) {
+	switch len(args) {
+	case 0:
+		value, _, err := instance.Var("file.path")
+		if err != nil {
+			return "", err
+		}
+		if name, ok := value.(string); ok {
+			return name, nil
+		}
+		return "", errors.New(`expecting a path argument or "file.path" variable`)
+	case 1:
+		if name, ok := args[0].(string); ok {
+			return name, nil
+		}
+		return "", errors.New("expecting a string path argument")
+	default:
+		return "", errors.New("expecting at most one path argument")
+	}
+}
+
+// lookupID returns the name of a user or group with a numeric id listed in a database file of a
+// filesystem such as /etc/passwd, falling back to lookupHost for HostFileSystem and to the id
+func lookupID(fsys FileSystem, database string, id uint32, lookupHost func(id string) (string, error)) string {
+	s := strconv.FormatUint(uint64(id), 10)
+	if content, err := fsys.ReadFile(database); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Split(line, ":")
+			if len(fields) >= 3 && fields[2] == s && !strings.HasPrefix(fields[0], "#") {
+				return fields[0]
+			}
+		}
+	}
+	if fsys == HostFileSystem {
+		if name, err := lookupHost(s); err == nil {
+			return name
+		}
+	}
+	return s
+}
+
+// fileMode converts os.FileMode to unix permission bits
+func fileMode(mode os.FileMode) uint64 {
+	result := uint64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		result |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		result |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		result |= 01000
+	}
+	return result
+}
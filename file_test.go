@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func newFileFixtures(t *testing.T) (string, func()) {
+	t.Helper()
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "expressionist")
+	assert.NoError(err)
+
+	assert.NoError(os.MkdirAll(filepath.Join(root, "etc", "ssh"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "etc", "passwd"), []byte("root:x:0:0::/root:/bin/bash\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "etc", "group"), []byte("# groups\nwheel:x:0:\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "etc", "ssh", "sshd_config"), []byte("PermitRootLogin no\n"), 0600))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "etc", "ssh", "ssh_host_rsa_key"), []byte("secret"), 0600))
+	assert.NoError(os.Symlink("/etc/passwd", filepath.Join(root, "etc", "passwd.link")))
+	assert.NoError(os.Chmod(filepath.Join(root, "etc", "ssh"), 0755|os.ModeSetgid|os.ModeSticky))
+
+	mtime := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.Local)
+	assert.NoError(os.Chtimes(filepath.Join(root, "etc", "passwd"), mtime, mtime))
+
+	return root, func() {
+		os.RemoveAll(root)
+	}
+}
+
+func TestFileFunctions(t *testing.T) {
+	root, cleanup := newFileFixtures(t)
+	defer cleanup()
+
+	functions := FileFunctions(DirFS(root))
+
+	// Names come from /etc/passwd and /etc/group of the fixtures, listing only id 0
+	current, err := user.Current()
+	assert.NoError(t, err)
+	owner, group := current.Uid, current.Gid
+	if owner == "0" {
+		owner = "root"
+	}
+	if group == "0" {
+		group = "wheel"
+	}
+
+	instanceTests{
+		{
+			name:         "exists",
+			expression:   `exists("/etc/passwd")`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "not exists",
+			expression:   `exists("/etc/shadow")`,
+			functions:    functions,
+			expectResult: false,
+		},
+		{
+			name:         "stat mode",
+			expression:   `stat.mode("/etc/ssh/sshd_config")`,
+			functions:    functions,
+			expectResult: uint64(0600),
+		},
+		{
+			name:         "stat mode special bits",
+			expression:   `stat.mode("/etc/ssh") & 03000`,
+			functions:    functions,
+			expectResult: uint64(03000),
+		},
+		{
+			name:         "owner",
+			expression:   `owner("/etc/passwd")`,
+			functions:    functions,
+			expectResult: owner,
+		},
+		{
+			name:         "group",
+			expression:   `group("/etc/passwd")`,
+			functions:    functions,
+			expectResult: group,
+		},
+		{
+			name:         "size",
+			expression:   `size("/etc/passwd") == 28`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "mtime",
+			expression:   `mtime("/etc/passwd")`,
+			functions:    functions,
+			expectResult: time.Date(2020, time.March, 1, 12, 0, 0, 0, time.Local),
+		},
+		{
+			name:         "readlink",
+			expression:   `readlink("/etc/passwd.link")`,
+			functions:    functions,
+			expectResult: "/etc/passwd",
+		},
+		{
+			name:         "content",
+			expression:   `content("/etc/ssh/sshd_config") =~ "PermitRootLogin no"`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "sha256",
+			expression:   `sha256("/etc/ssh/ssh_host_rsa_key")`,
+			functions:    functions,
+			expectResult: "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b",
+		},
+		{
+			name:       "file path variable",
+			expression: `size() > 0 && stat.mode() == 0644`,
+			vars: VarMap{
+				"file.path": "/etc/passwd",
+			},
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:        "missing file",
+			expression:  `size("/etc/shadow")`,
+			functions:   functions,
+			expectError: newLexerError(0, fmt.Sprintf(`call to "size()" failed: stat %s/etc/shadow: no such file or directory`, root)),
+		},
+		{
+			name:        "missing path",
+			expression:  `content()`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "content()" failed: expecting a path argument or "file.path" variable`),
+		},
+	}.Run(t)
+}
+
+func TestFilePathArg(t *testing.T) {
+	tests := []struct {
+		name        string
+		vars        VarMap
+		args        []interface{}
+		expectPath  string
+		expectError string
+	}{
+		{
+			name:       "argument",
+			args:       []interface{}{"/etc/passwd"},
+			expectPath: "/etc/passwd",
+		},
+		{
+			name: "variable",
+			vars: VarMap{
+				"file.path": "/etc/group",
+			},
+			expectPath: "/etc/group",
+		},
+		{
+			name:        "missing",
+			expectError: `expecting a path argument or "file.path" variable`,
+		},
+		{
+			name:        "invalid argument",
+			args:        []interface{}{int64(1)},
+			expectError: "expecting a string path argument",
+		},
+		{
+			name:        "too many arguments",
+			args:        []interface{}{"/etc/passwd", "/etc/group"},
+			expectError: "expecting at most one path argument",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			name, err := filePathArg(&Instance{Vars: test.vars}, test.args)
+			if test.expectError != "" {
+				assert.EqualError(err, test.expectError)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectPath, name)
+			}
+		})
+	}
+}
+
+func TestFileFunctionsIterable(t *testing.T) {
+	assert := assert.New(t)
+	root, cleanup := newFileFixtures(t)
+	defer cleanup()
+
+	fsys := DirFS(root)
+	global := &Instance{
+		Functions: FileFunctions(fsys),
+	}
+
+	path, err := ParsePath(`/etc/ssh/ssh*`)
+	assert.NoError(err)
+
+	it, err := path.Resolve(global, fsys)
+	assert.NoError(err)
+
+	expr, err := ParseIterable(`all(stat.mode() & 077 == 0 && content() != "")`)
+	assert.NoError(err)
+
+	result, err := expr.Evaluate(it, global)
+	assert.NoError(err)
+	assert.True(result.Passed)
+}
+
+func TestFileOwnership(t *testing.T) {
+	root, cleanup := newFileFixtures(t)
+	defer cleanup()
+
+	current, err := user.Current()
+	assert.NoError(t, err)
+	group, err := user.LookupGroupId(current.Gid)
+	assert.NoError(t, err)
+
+	host := FileFunctions(HostFileSystem)
+	mem := FileFunctions(newMemFileSystem(map[string]string{"/etc/passwd": ""}))
+
+	instanceTests{
+		{
+			name:         "host owner",
+			expression:   `owner(path) == user && group(path) == group`,
+			vars:         VarMap{"path": filepath.ToSlash(filepath.Join(root, "etc", "passwd")), "user": current.Username, "group": group.Name},
+			functions:    host,
+			expectResult: true,
+		},
+		{
+			name:        "unsupported filesystem",
+			expression:  `owner("/etc/passwd")`,
+			functions:   mem,
+			expectError: newLexerError(0, `call to "owner()" failed: file ownership is not supported by the filesystem`),
+		},
+	}.Run(t)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format returns canonical source of a syntax tree, which parses back to an equivalent tree.
+//
+// Operators are separated by single spaces, strings are double-quoted, durations and sizes use
+// the largest units and redundant parentheses are dropped, so that formatting is idempotent.
+// Parentheses grouping || within && or vice versa on the rhs are kept even though boolean
+// operators are right associative.
+func Format(node Node) string {
+	var b strings.Builder
+	node.format(&b)
+	return b.String()
+}
+
+func (e *IterableExpression) format(b *strings.Builder) {
+	if e.IterableComparison != nil {
+		e.IterableComparison.format(b)
+		return
+	}
+	e.Expression.format(b)
+}
+
+func (c *IterableComparison) format(b *strings.Builder) {
+	b.WriteString(*c.Fn)
+	b.WriteString("(")
+	c.Expression.format(b)
+	b.WriteString(")")
+	if c.ScalarComparison != nil {
+		c.ScalarComparison.format(b)
+	}
+}
+
+func (e *PathExpression) format(b *strings.Builder) {
+	if e.Path != nil {
+		b.WriteString(*e.Path)
+		return
+	}
+	e.Expression.format(b)
+}
+
+func (e *Expression) format(b *strings.Builder) {
+	if e.Let != nil {
+		e.Let.format(b)
+		return
+	}
+
+	// An expression consisting only of a parenthesised expression needs no parentheses,
+	// since boolean operators are right associative
+	if inner := e.subexpression(); inner != nil {
+		inner.format(b)
+		return
+	}
+
+	// Neither does a parenthesised comparison on the lhs of a boolean operator
+	if inner := e.Comparison.subexpression(); inner != nil && inner.Comparison != nil && inner.Next == nil {
+		inner.Comparison.format(b)
+	} else {
+		e.Comparison.format(b)
+	}
+	if e.Next == nil {
+		return
+	}
+	b.WriteString(" " + *e.Op + " ")
+
+	// Parentheses around a different boolean operator are kept for readability
+	if inner := e.Next.subexpression(); inner != nil && inner.Op != nil && *inner.Op != *e.Op {
+		b.WriteString("(")
+		inner.format(b)
+		b.WriteString(")")
+		return
+	}
+	e.Next.format(b)
+}
+
+// subexpression returns the innermost parenthesised expression an expression consists of or nil
+func (e *Expression) subexpression() *Expression {
+	if e.Let != nil || e.Next != nil {
+		return nil
+	}
+	return e.Comparison.subexpression()
+}
+
+// value returns the value an expression consists of or nil
+func (e *Expression) value() *Value {
+	if e.Let != nil || e.Next != nil {
+		return nil
+	}
+	return e.Comparison.value()
+}
+
+func (l *Let) format(b *strings.Builder) {
+	b.WriteString("let ")
+	for i, binding := range l.Bindings {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		binding.format(b)
+	}
+	b.WriteString(" in ")
+	l.Body.format(b)
+}
+
+func (b *Binding) format(w *strings.Builder) {
+	w.WriteString(b.Name + " = ")
+	b.Value.format(w)
+}
+
+// subexpression returns the innermost parenthesised expression a comparison consists of or nil
+func (c *Comparison) subexpression() *Expression {
+	value := c.value()
+	if value == nil || value.Subexpression == nil {
+		return nil
+	}
+	if inner := value.Subexpression.subexpression(); inner != nil {
+		return inner
+	}
+	return value.Subexpression
+}
+
+// value returns the value a comparison consists of or nil
+func (c *Comparison) value() *Value {
+	if c.Term == nil || c.ScalarComparison != nil || c.ArrayComparison != nil || c.Term.Next != nil || c.Term.Unary.Value == nil {
+		return nil
+	}
+	return c.Term.Unary.Value
+}
+
+func (c *Comparison) format(b *strings.Builder) {
+	if c.Term != nil {
+		c.Term.format(b)
+	} else {
+		formatValues(b, c.Values)
+	}
+	switch {
+	case c.ScalarComparison != nil:
+		c.ScalarComparison.format(b)
+	case c.ArrayComparison != nil:
+		c.ArrayComparison.format(b)
+	}
+}
+
+func (c *ScalarComparison) format(b *strings.Builder) {
+	b.WriteString(" " + formatOp(*c.Op) + " ")
+	c.Next.format(b)
+}
+
+func (c *ArrayComparison) format(b *strings.Builder) {
+	b.WriteString(" " + formatOp(*c.Op) + " ")
+	c.Array.format(b)
+}
+
+// formatOp returns source of an operator captured from several tokens
+func formatOp(op string) string {
+	switch {
+	case op == "anyof":
+		return "any of"
+	case op == "allof":
+		return "all of"
+	case strings.HasPrefix(op, "not") && op != "not":
+		return "not " + strings.TrimPrefix(op, "not")
+	default:
+		return op
+	}
+}
+
+func (t *Term) format(b *strings.Builder) {
+	t.Unary.format(b)
+	if t.Next != nil {
+		b.WriteString(" " + *t.Op + " ")
+		t.Next.format(b)
+	}
+}
+
+func (u *Unary) format(b *strings.Builder) {
+	if u.Value != nil {
+		u.Value.format(b)
+		return
+	}
+	b.WriteString(*u.Op)
+	u.Unary.format(b)
+}
+
+func (a *Array) format(b *strings.Builder) {
+	switch {
+	case a.Call != nil:
+		a.Call.format(b)
+	case a.Ident != nil:
+		b.WriteString(*a.Ident)
+	default:
+		formatValues(b, a.Values)
+	}
+}
+
+// formatValues formats values of an array literal
+func formatValues(b *strings.Builder, values []Value) {
+	b.WriteString("[")
+	for i := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		values[i].format(b)
+	}
+	b.WriteString("]")
+}
+
+func (v *Value) format(b *strings.Builder) {
+	switch {
+	case v.Size != nil:
+		b.WriteString(formatSize(int64(*v.Size)))
+	case v.Duration != nil:
+		b.WriteString(formatDuration(time.Duration(*v.Duration)))
+	case v.Hex != nil:
+		b.WriteString(*v.Hex)
+	case v.Octal != nil:
+		b.WriteString(*v.Octal)
+	case v.Decimal != nil:
+		b.WriteString(strconv.FormatInt(*v.Decimal, 10))
+	case v.String != nil:
+		b.WriteString(strconv.Quote(*v.String))
+	case v.Call != nil:
+		v.Call.format(b)
+	case v.Variable != nil:
+		b.WriteString(*v.Variable)
+	case v.Subexpression != nil:
+		// A parenthesised value needs no parentheses
+		if value := v.Subexpression.value(); value != nil {
+			value.format(b)
+			return
+		}
+		b.WriteString("(")
+		v.Subexpression.format(b)
+		b.WriteString(")")
+	}
+}
+
+func (c *Call) format(b *strings.Builder) {
+	b.WriteString(c.Name)
+	b.WriteString("(")
+	for i, arg := range c.Args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		arg.format(b)
+	}
+	b.WriteString(")")
+}
+
+// formatSize returns a size literal using the largest unit dividing the size
+func formatSize(size int64) string {
+	units := []string{"PiB", "PB", "TiB", "TB", "GiB", "GB", "MiB", "MB", "KiB", "kB"}
+	if size != 0 {
+		for _, unit := range units {
+			if size%sizeUnits[unit] == 0 {
+				return strconv.FormatInt(size/sizeUnits[unit], 10) + unit
+			}
+		}
+	}
+	return strconv.FormatInt(size, 10) + "B"
+}
+
+// formatDuration returns a duration literal as a sequence of units from days to nanoseconds
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "0s"
+	}
+
+	var b strings.Builder
+	if d < 0 {
+		b.WriteString("-")
+		d = -d
+	}
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+		{"ms", time.Millisecond},
+		{"us", time.Microsecond},
+		{"ns", time.Nanosecond},
+	}
+	for _, u := range units {
+		if n := d / u.unit; n != 0 {
+			b.WriteString(strconv.FormatInt(int64(n), 10) + u.suffix)
+			d -= n * u.unit
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alecthomas/participle/lexer"
+	assert "github.com/stretchr/testify/require"
+)
+
+// clearPositions zeroes positions of a syntax tree so that trees parsed from
+// differently formatted sources may be compared
+func clearPositions(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			clearPositions(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearPositions(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(lexer.Position{}) {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			clearPositions(v.Field(i))
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		expected   string
+	}{
+		{
+			name:       "canonical",
+			expression: `file.owner == "root" && file.permissions & 022 == 0`,
+			expected:   `file.owner == "root" && file.permissions & 022 == 0`,
+		},
+		{
+			name:       "spacing",
+			expression: `a==1&&b!=2||c>=3`,
+			expected:   `a == 1 && b != 2 || c >= 3`,
+		},
+		{
+			name:       "multi-token operators",
+			expression: `a  not   in [1,2] && b any  of c && d all of f(x) && e not like "x%" && g ==~ "h" && i not contains "j"`,
+			expected:   `a not in [1, 2] && b any of c && d all of f(x) && e not like "x%" && g ==~ "h" && i not contains "j"`,
+		},
+		{
+			name:       "set operators",
+			expression: `a subset b && a superset [ "x" ]`,
+			expected:   `a subset b && a superset ["x"]`,
+		},
+		{
+			name:       "array literal lhs",
+			expression: `[ 1,2 ] any of [2,3] && ([a] subset b)`,
+			expected:   `[1, 2] any of [2, 3] && [a] subset b`,
+		},
+		{
+			name:       "strings",
+			expression: "'it\\'s' + `a\\b` + \"tab\\t\" + \"é\"",
+			expected:   `"it's" + "a\\b" + "tab\t" + "é"`,
+		},
+		{
+			name:       "integers",
+			expression: `x in [0x1F, 0644, -12, +3]`,
+			expected:   `x in [0x1F, 0644, -12, 3]`,
+		},
+		{
+			name:       "durations",
+			expression: `x in [90m, 1w, 1h0m30s, 1500ms, -2h, 0s]`,
+			expected:   `x in [1h30m, 7d, 1h30s, 1s500ms, -2h, 0s]`,
+		},
+		{
+			name:       "sizes",
+			expression: `x in [1024KiB, 1000kB, 1.5GiB, 0B, 3B, -2GiB]`,
+			expected:   `x in [1MiB, 1MB, 1536MiB, 0B, 3B, -2GiB]`,
+		},
+		{
+			name:       "redundant parentheses",
+			expression: `((a == 1)) && (b) && ((f(x)))`,
+			expected:   `a == 1 && b && f(x)`,
+		},
+		{
+			name:       "parentheses of same rhs operator",
+			expression: `a && (b && c)`,
+			expected:   `a && b && c`,
+		},
+		{
+			name:       "parentheses of different rhs operator",
+			expression: `a && ((b || c))`,
+			expected:   `a && (b || c)`,
+		},
+		{
+			name:       "parentheses of lhs",
+			expression: `(a || b) && c`,
+			expected:   `(a || b) && c`,
+		},
+		{
+			name:       "parentheses in terms",
+			expression: `(a | b) & c == (d)`,
+			expected:   `(a | b) & c == d`,
+		},
+		{
+			name:       "unary",
+			expression: `!(a == b) && ! c && -(x) == ^ y`,
+			expected:   `!(a == b) && !c && -x == ^y`,
+		},
+		{
+			name:       "call arguments",
+			expression: `f( (a), b+c, g() )`,
+			expected:   `f(a, b + c, g())`,
+		},
+		{
+			name:       "let",
+			expression: `let x=f(a),y = (x),z=(x == 1) in (x != y && z)`,
+			expected:   `let x = f(a), y = x, z = (x == 1) in x != y && z`,
+		},
+		{
+			name:       "parenthesised let",
+			expression: `(let x = 1 in x == 1) && (let y = 2 in y | 1) == 3 && (let z = 3 in z == 3)`,
+			expected:   `(let x = 1 in x == 1) && (let y = 2 in y | 1) == 3 && let z = 3 in z == 3`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			expr, err := ParseExpression(test.expression)
+			assert.NoError(err)
+
+			formatted := Format(expr)
+			assert.Equal(test.expected, formatted)
+
+			reparsed, err := ParseExpression(formatted)
+			assert.NoError(err)
+			assert.Equal(formatted, Format(reparsed), "expected idempotent formatting")
+
+			canonical, err := ParseExpression(test.expected)
+			assert.NoError(err)
+			clearPositions(reflect.ValueOf(reparsed))
+			clearPositions(reflect.ValueOf(canonical))
+			assert.Equal(canonical, reparsed, "expected canonical source to round-trip")
+		})
+	}
+}
+
+func TestFormatEvaluation(t *testing.T) {
+	expressions := []string{
+		`1h30m == 90m && 1GiB == 1024MiB`,
+		`-(1) == -1 && -(1h) < 0s`,
+		`("a" + "b") + ("c" + "d") == "abcd"`,
+		`0x0F & (0xF0 | 0x3) == 3`,
+		`!(true_var && false_var) && (false_var || true_var)`,
+		`"a\tb" =~ "^a\\sb$"`,
+	}
+
+	instance := &Instance{
+		Vars: VarMap{
+			"true_var":  true,
+			"false_var": false,
+		},
+	}
+	for _, expression := range expressions {
+		t.Run(expression, func(t *testing.T) {
+			assert := assert.New(t)
+			expr, err := ParseExpression(expression)
+			assert.NoError(err)
+			expected, err := expr.Evaluate(instance)
+			assert.NoError(err)
+			assert.Equal(true, expected)
+
+			reparsed, err := ParseExpression(Format(expr))
+			assert.NoError(err)
+			actual, err := reparsed.Evaluate(instance)
+			assert.NoError(err)
+			assert.Equal(expected, actual)
+		})
+	}
+}
+
+func TestFormatIterableAndPath(t *testing.T) {
+	assert := assert.New(t)
+	iterable, err := ParseIterable(`len(file.owner=="root")>(3)`)
+	assert.NoError(err)
+	assert.Equal(`len(file.owner == "root") > 3`, Format(iterable))
+
+	path, err := ParsePath(`/etc/ssh/*_config`)
+	assert.NoError(err)
+	assert.Equal(`/etc/ssh/*_config`, Format(path))
+
+	path, err = ParsePath(`"/etc/" + (name)`)
+	assert.NoError(err)
+	assert.Equal(`"/etc/" + name`, Format(path))
+}
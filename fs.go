@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileSystem abstracts read-only access to a hierarchical filesystem in the spirit of io/fs.FS.
+// Names are absolute slash-separated paths.
+type FileSystem interface {
+	// Stat returns file info for a named file following symbolic links
+	Stat(name string) (os.FileInfo, error)
+	// Lstat returns file info for a named file without following symbolic links
+	Lstat(name string) (os.FileInfo, error)
+	// ReadDir returns entries of a named directory sorted by name without following symbolic links
+	ReadDir(name string) ([]os.FileInfo, error)
+	// Readlink returns the destination of a named symbolic link
+	Readlink(name string) (string, error)
+	// ReadFile returns the contents of a named file
+	ReadFile(name string) ([]byte, error)
+}
+
+// HostFileSystem provides access to the filesystem of the host
+var HostFileSystem FileSystem = DirFS("/")
+
+// DirFS returns a FileSystem for the tree of files rooted at the directory root. Names are
+// confined to the tree: ".." stops at the root and absolute symbolic links are resolved
+// relative to it.
+func DirFS(root string) FileSystem {
+	return dirFileSystem(root)
+}
+
+type dirFileSystem string
+
+// maxSymlinks bounds the number of symbolic links followed while resolving a name
+const maxSymlinks = 40
+
+// resolve returns the host path of a named file, resolving ".." and symbolic links within the
+// root as if it was the root of the filesystem, so that no name escapes it. The last element
+// of the name is resolved only when follow is set.
+func (d dirFileSystem) resolve(name string, follow bool) (string, error) {
+	var (
+		resolved = "/"
+		pending  = strings.Split(name, "/")
+		links    int
+	)
+	for len(pending) != 0 {
+		elem := pending[0]
+		pending = pending[1:]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, elem)
+		if len(pending) == 0 && !follow {
+			resolved = next
+			break
+		}
+		info, err := os.Lstat(d.hostPath(next))
+		if err != nil && len(pending) == 0 {
+			// Accessing the last element reports the error
+			resolved = next
+			break
+		}
+		if err != nil {
+			// Nothing below an element that can't be resolved exists, fail as accessing it would
+			if pathErr, ok := err.(*os.PathError); ok {
+				return "", &os.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+			}
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", &os.PathError{Op: "resolve", Path: name, Err: errors.New("too many levels of symbolic links")}
+		}
+		target, err := os.Readlink(d.hostPath(next))
+		if err != nil {
+			return "", err
+		}
+		target = filepath.ToSlash(target)
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return d.hostPath(resolved), nil
+}
+
+// hostPath returns the host path of a clean absolute name
+func (d dirFileSystem) hostPath(name string) string {
+	return filepath.Join(string(d), filepath.FromSlash(name))
+}
+
+func (d dirFileSystem) Stat(name string) (os.FileInfo, error) {
+	name, err := d.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(name)
+}
+
+func (d dirFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	name, err := d.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadDir(name)
+}
+
+func (d dirFileSystem) Lstat(name string) (os.FileInfo, error) {
+	name, err := d.resolve(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(name)
+}
+
+func (d dirFileSystem) Readlink(name string) (string, error) {
+	name, err := d.resolve(name, false)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(name)
+}
+
+func (d dirFileSystem) ReadFile(name string) ([]byte, error) {
+	name, err := d.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(name)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+// memFileSystem is an in-memory FileSystem built from a map of file paths to contents.
+// Paths ending with a slash denote empty directories.
+type memFileSystem map[string]*memFile
+
+type memFile struct {
+	name    string
+	mode    os.FileMode
+	content string
+}
+
+func (f *memFile) Name() string       { return path.Base(f.name) }
+func (f *memFile) Size() int64        { return int64(len(f.content)) }
+func (f *memFile) Mode() os.FileMode  { return f.mode }
+func (f *memFile) ModTime() time.Time { return time.Time{} }
+func (f *memFile) IsDir() bool        { return f.mode.IsDir() }
+func (f *memFile) Sys() interface{}   { return nil }
+
+func newMemFileSystem(files map[string]string) memFileSystem {
+	fsys := memFileSystem{
+		"/": {name: "/", mode: os.ModeDir | 0755},
+	}
+	for name, content := range files {
+		if strings.HasSuffix(name, "/") {
+			name = strings.TrimSuffix(name, "/")
+			fsys[name] = &memFile{name: name, mode: os.ModeDir | 0755}
+		} else {
+			fsys[name] = &memFile{name: name, mode: 0644, content: content}
+		}
+		for dir := path.Dir(name); dir != "/"; dir = path.Dir(dir) {
+			fsys[dir] = &memFile{name: dir, mode: os.ModeDir | 0755}
+		}
+	}
+	return fsys
+}
+
+func (m memFileSystem) Stat(name string) (os.FileInfo, error) {
+	f, ok := m[path.Clean(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return f, nil
+}
+
+func (m memFileSystem) Lstat(name string) (os.FileInfo, error) {
+	return m.Stat(name)
+}
+
+func (m memFileSystem) Readlink(name string) (string, error) {
+	return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrInvalid}
+}
+
+func (m memFileSystem) ReadFile(name string) ([]byte, error) {
+	info, err := m.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(info.(*memFile).content), nil
+}
+
+func (m memFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	name = path.Clean(name)
+	f, ok := m[name]
+	if !ok || !f.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+	if f.mode&0444 == 0 {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrPermission}
+	}
+	var entries []os.FileInfo
+	for child, f := range m {
+		if child != "/" && path.Dir(child) == name {
+			entries = append(entries, f)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func TestDirFS(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "expressionist")
+	assert.NoError(err)
+	defer os.RemoveAll(root)
+
+	assert.NoError(os.MkdirAll(filepath.Join(root, "etc", "ssh"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "etc", "ssh", "sshd_config"), []byte("PermitRootLogin no\n"), 0600))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "etc", "passwd"), []byte("root:x:0:0::/root:/bin/bash\n"), 0644))
+
+	fsys := DirFS(root)
+
+	info, err := fsys.Stat("/etc/ssh/sshd_config")
+	assert.NoError(err)
+	assert.Equal("sshd_config", info.Name())
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	_, err = fsys.Stat("/etc/shadow")
+	assert.True(os.IsNotExist(err))
+
+	assert.NoError(os.Symlink("passwd", filepath.Join(root, "etc", "passwd.link")))
+
+	info, err = fsys.Lstat("/etc/passwd.link")
+	assert.NoError(err)
+	assert.NotZero(info.Mode() & os.ModeSymlink)
+
+	link, err := fsys.Readlink("/etc/passwd.link")
+	assert.NoError(err)
+	assert.Equal("passwd", link)
+
+	content, err := fsys.ReadFile("/etc/passwd.link")
+	assert.NoError(err)
+	assert.Equal("root:x:0:0::/root:/bin/bash\n", string(content))
+
+	entries, err := fsys.ReadDir("/etc")
+	assert.NoError(err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal([]string{"passwd", "passwd.link", "ssh"}, names)
+}
+
+func TestDirFSConfinement(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "expressionist")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+	assert.NoError(os.MkdirAll(filepath.Join(root, "etc"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(root, "etc", "passwd"), []byte("root\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "secret"), []byte("secret\n"), 0644))
+	assert.NoError(os.Symlink("/etc/passwd", filepath.Join(root, "etc", "absolute.link")))
+	assert.NoError(os.Symlink("../../secret", filepath.Join(root, "etc", "relative.link")))
+	assert.NoError(os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "etc", "host.link")))
+	assert.NoError(os.Symlink("/", filepath.Join(root, "etc", "root.link")))
+	assert.NoError(os.Symlink(dir, filepath.Join(root, "escape")))
+	assert.NoError(os.Symlink("loop.link", filepath.Join(root, "etc", "loop.link")))
+
+	fsys := DirFS(root)
+
+	for _, name := range []string{"/etc/passwd", "/etc/absolute.link", "/../etc/passwd", "/etc/root.link/etc/passwd", "../../etc/root.link/../etc/./passwd"} {
+		content, err := fsys.ReadFile(name)
+		assert.NoError(err, name)
+		assert.Equal("root\n", string(content), name)
+	}
+
+	for _, name := range []string{"/../secret", "/etc/relative.link", "/etc/host.link", "/etc/root.link/../secret", "/escape/secret", "/missing/../escape/secret", "/missing/../etc/host.link", "/etc/passwd/../../escape/secret"} {
+		_, err := fsys.ReadFile(name)
+		assert.True(os.IsNotExist(err), name)
+	}
+
+	info, err := fsys.Lstat("/etc/root.link")
+	assert.NoError(err)
+	assert.NotZero(info.Mode() & os.ModeSymlink)
+
+	info, err = fsys.Stat("/etc/root.link")
+	assert.NoError(err)
+	assert.True(info.IsDir())
+
+	_, err = fsys.Stat("/etc/loop.link")
+	assert.EqualError(err, "resolve /etc/loop.link: too many levels of symbolic links")
+}
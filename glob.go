@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+var errUnbalancedBraces = errors.New("unbalanced braces in pattern")
+
+// Glob returns sorted absolute paths of files in a filesystem matching a pattern.
+//
+// Besides the syntax of path.Match, patterns support "**" segments matching any number of
+// nested directories and "{a,b}" alternation. Files that don't exist match no pattern, while
+// other I/O errors such as unreadable directories are returned, since ignoring them would leave
+// out files that match.
+func Glob(fsys FileSystem, pattern string) ([]string, error) {
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[string]struct{})
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(pattern, "/") {
+			return nil, path.ErrBadPattern
+		}
+
+		var segments []string
+		for _, segment := range strings.Split(pattern, "/") {
+			if segment == "" {
+				continue
+			}
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, err
+			}
+			segments = append(segments, segment)
+		}
+		if err := globSegments(fsys, "/", segments, matches); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]string, 0, len(matches))
+	for match := range matches {
+		result = append(result, match)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func globSegments(fsys FileSystem, dir string, segments []string, matches map[string]struct{}) error {
+	if len(segments) == 0 {
+		if _, err := fsys.Stat(dir); err != nil {
+			return ignoreMissing(err)
+		}
+		matches[dir] = struct{}{}
+		return nil
+	}
+
+	segment, rest := segments[0], segments[1:]
+	switch {
+	case segment == "**":
+		if err := globSegments(fsys, dir, rest, matches); err != nil {
+			return err
+		}
+
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			return ignoreMissing(err)
+		}
+		for _, entry := range entries {
+			name := path.Join(dir, entry.Name())
+			if entry.IsDir() {
+				if err := globSegments(fsys, name, segments, matches); err != nil {
+					return err
+				}
+			} else if len(rest) == 0 {
+				matches[name] = struct{}{}
+			}
+		}
+
+	case strings.ContainsAny(segment, `*?[\`):
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			return ignoreMissing(err)
+		}
+		for _, entry := range entries {
+			if ok, _ := path.Match(segment, entry.Name()); ok {
+				if err := globSegments(fsys, path.Join(dir, entry.Name()), rest, matches); err != nil {
+					return err
+				}
+			}
+		}
+
+	default:
+		return globSegments(fsys, path.Join(dir, segment), rest, matches)
+	}
+	return nil
+}
+
+// ignoreMissing returns nil for errors caused by files that don't exist or aren't directories
+func ignoreMissing(err error) error {
+	if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+		return nil
+	}
+	return err
+}
+
+// expandBraces expands "{a,b}" alternations of a pattern, including nested ones
+func expandBraces(pattern string) ([]string, error) {
+	var (
+		alternatives []string
+		depth        int
+		start, last  int
+	)
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start, last = i, i+1
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				alternatives = append(alternatives, pattern[last:i])
+				last = i + 1
+			}
+		case '}':
+			if depth == 0 {
+				return nil, errUnbalancedBraces
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+
+			alternatives = append(alternatives, pattern[last:i])
+			var result []string
+			for _, alternative := range alternatives {
+				expanded, err := expandBraces(pattern[:start] + alternative + pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, expanded...)
+			}
+			return result, nil
+		}
+	}
+	if depth != 0 {
+		return nil, errUnbalancedBraces
+	}
+	return []string{pattern}, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	fsys := newMemFileSystem(map[string]string{
+		"/etc/passwd":                            "",
+		"/etc/group":                             "",
+		"/etc/ssh/sshd_config":                   "",
+		"/etc/ssh/sshd_config.d/10-auth.conf":    "",
+		"/etc/ssh/sshd_config.d/20-ciphers.conf": "",
+		"/etc/ssh/ssh_host_rsa_key":              "",
+		"/etc/ssh/ssh_host_rsa_key.pub":          "",
+		"/home/alice/.ssh/authorized_keys":       "",
+		"/home/bob/.ssh/authorized_keys":         "",
+		"/home/carol/":                           "",
+		"/var/run/docker.sock":                   "",
+		"/var/run/containerd/containerd.sock":    "",
+	})
+
+	tests := []struct {
+		name        string
+		pattern     string
+		expectPaths []string
+		expectError error
+	}{
+		{
+			name:        "literal",
+			pattern:     "/etc/passwd",
+			expectPaths: []string{"/etc/passwd"},
+		},
+		{
+			name:        "missing literal",
+			pattern:     "/etc/shadow",
+			expectPaths: []string{},
+		},
+		{
+			name:        "wildcard",
+			pattern:     "/var/run/*.sock",
+			expectPaths: []string{"/var/run/docker.sock"},
+		},
+		{
+			name:    "wildcard in directory",
+			pattern: "/home/*/.ssh/authorized_keys",
+			expectPaths: []string{
+				"/home/alice/.ssh/authorized_keys",
+				"/home/bob/.ssh/authorized_keys",
+			},
+		},
+		{
+			name:    "character class",
+			pattern: "/etc/ssh/sshd_config.d/[0-1]*.conf",
+			expectPaths: []string{
+				"/etc/ssh/sshd_config.d/10-auth.conf",
+			},
+		},
+		{
+			name:    "recursive",
+			pattern: "/var/**/*.sock",
+			expectPaths: []string{
+				"/var/run/containerd/containerd.sock",
+				"/var/run/docker.sock",
+			},
+		},
+		{
+			name:    "trailing recursive",
+			pattern: "/etc/ssh/sshd_config.d/**",
+			expectPaths: []string{
+				"/etc/ssh/sshd_config.d",
+				"/etc/ssh/sshd_config.d/10-auth.conf",
+				"/etc/ssh/sshd_config.d/20-ciphers.conf",
+			},
+		},
+		{
+			name:    "alternation",
+			pattern: "/etc/{passwd,group,shadow}",
+			expectPaths: []string{
+				"/etc/group",
+				"/etc/passwd",
+			},
+		},
+		{
+			name:    "nested alternation",
+			pattern: "/etc/ssh/ssh{d_config,_host_rsa_key{,.pub}}",
+			expectPaths: []string{
+				"/etc/ssh/ssh_host_rsa_key",
+				"/etc/ssh/ssh_host_rsa_key.pub",
+				"/etc/ssh/sshd_config",
+			},
+		},
+		{
+			name:        "unbalanced braces",
+			pattern:     "/etc/{passwd",
+			expectError: errUnbalancedBraces,
+		},
+		{
+			name:        "bad pattern",
+			pattern:     "/etc/[",
+			expectError: path.ErrBadPattern,
+		},
+		{
+			name:        "relative pattern",
+			pattern:     "etc/passwd",
+			expectError: path.ErrBadPattern,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			paths, err := Glob(fsys, test.pattern)
+			if test.expectError != nil {
+				assert.Equal(test.expectError, err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectPaths, paths)
+			}
+		})
+	}
+}
+
+func TestGlobUnreadableDirectory(t *testing.T) {
+	assert := assert.New(t)
+	fsys := newMemFileSystem(map[string]string{
+		"/etc/passwd":           "",
+		"/root/.ssh/id_rsa.key": "",
+	})
+	fsys["/root"].mode = os.ModeDir
+
+	for _, pattern := range []string{"/root/**/*.key", "/root/*/id_rsa.key", "/**/*.key"} {
+		_, err := Glob(fsys, pattern)
+		assert.EqualError(err, "readdir /root: permission denied", pattern)
+	}
+
+	paths, err := Glob(fsys, "/{etc,root/.ssh}/*")
+	assert.NoError(err)
+	assert.Equal([]string{"/etc/passwd", "/root/.ssh/id_rsa.key"}, paths)
+}
@@ -6,4 +6,5 @@ require (
 	github.com/alecthomas/participle v0.5.0
 	github.com/alecthomas/repr v0.0.0-20200325044227-4184120f674c
 	github.com/stretchr/testify v1.4.0
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -0,0 +1,717 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/participle/lexer"
+)
+
+// ASTVersion is the version of the JSON encoding of syntax trees produced by MarshalNode
+const ASTVersion = 1
+
+// nodeTypes maps names of node types in the JSON encoding to constructors of nodes
+var nodeTypes = map[string]func() Node{
+	"expression":         func() Node { return &Expression{} },
+	"let":                func() Node { return &Let{} },
+	"binding":            func() Node { return &Binding{} },
+	"iterableExpression": func() Node { return &IterableExpression{} },
+	"iterableComparison": func() Node { return &IterableComparison{} },
+	"pathExpression":     func() Node { return &PathExpression{} },
+	"comparison":         func() Node { return &Comparison{} },
+	"scalarComparison":   func() Node { return &ScalarComparison{} },
+	"arrayComparison":    func() Node { return &ArrayComparison{} },
+	"term":               func() Node { return &Term{} },
+	"unary":              func() Node { return &Unary{} },
+	"array":              func() Node { return &Array{} },
+	"value":              func() Node { return &Value{} },
+	"call":               func() Node { return &Call{} },
+}
+
+type encodedNode struct {
+	Version int             `json:"version"`
+	Type    string          `json:"type"`
+	Node    json.RawMessage `json:"node"`
+}
+
+// MarshalNode encodes a syntax tree as JSON along with the version of the encoding and the type of the root
+func MarshalNode(node Node) ([]byte, error) {
+	data, err := json.Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+	for name, newNode := range nodeTypes {
+		if reflect.TypeOf(newNode()) == reflect.TypeOf(node) {
+			return json.Marshal(encodedNode{
+				Version: ASTVersion,
+				Type:    name,
+				Node:    data,
+			})
+		}
+	}
+	return nil, fmt.Errorf("unexpected node type %T", node)
+}
+
+// UnmarshalNode decodes a syntax tree encoded with MarshalNode, validating its structure,
+// so that it can be evaluated without parsing the source again
+func UnmarshalNode(data []byte) (Node, error) {
+	var encoded encodedNode
+	if err := decodeStrict(data, &encoded); err != nil {
+		return nil, err
+	}
+	if encoded.Version != ASTVersion {
+		return nil, fmt.Errorf("unsupported syntax tree version %d", encoded.Version)
+	}
+	newNode, ok := nodeTypes[encoded.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown node type %q", encoded.Type)
+	}
+	if len(encoded.Node) == 0 || string(encoded.Node) == "null" {
+		return nil, errors.New("missing node")
+	}
+
+	node := newNode()
+	if err := json.Unmarshal(encoded.Node, node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+type positionJSON struct {
+	Filename string `json:"filename,omitempty"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+func newPositionJSON(pos lexer.Position) positionJSON {
+	return positionJSON{
+		Filename: pos.Filename,
+		Offset:   pos.Offset,
+		Line:     pos.Line,
+		Column:   pos.Column,
+	}
+}
+
+func (p positionJSON) position() lexer.Position {
+	return lexer.Position{
+		Filename: p.Filename,
+		Offset:   p.Offset,
+		Line:     p.Line,
+		Column:   p.Column,
+	}
+}
+
+// opJSON returns an operator as written in source
+func opJSON(op *string) *string {
+	if op == nil {
+		return nil
+	}
+	s := formatOp(*op)
+	return &s
+}
+
+// parseOpJSON returns an operator as captured by the parser, checking that it's one of valid operators
+func parseOpJSON(op *string, valid ...string) (*string, error) {
+	if op == nil {
+		return nil, nil
+	}
+	s := strings.Replace(*op, " ", "", -1)
+	for _, v := range valid {
+		if s == v {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid operator %q", *op)
+}
+
+var (
+	booleanOps = []string{"&&", "||"}
+	scalarOps  = []string{
+		">=", "<=", ">", "<", "!=~", "==~", "!=", "==", "=~", "!~",
+		"like", "notlike", "ilike", "notilike", "glob", "notglob", "iglob", "notiglob", "contains", "notcontains",
+	}
+	arrayOps = []string{"in", "notin", "anyof", "allof", "subset", "superset"}
+	termOps  = []string{"&", "|", "^", "+", "-"}
+	unaryOps = []string{"!", "-", "^"}
+)
+
+// checkPair checks that an operator and its rhs are either both set or both missing
+func checkPair(op *string, hasNext bool) error {
+	switch {
+	case op != nil && !hasNext:
+		return fmt.Errorf("missing rhs of %s", *op)
+	case op == nil && hasNext:
+		return errors.New("missing operator")
+	}
+	return nil
+}
+
+// checkOneOf checks that exactly one of alternatives is set
+func checkOneOf(alternatives ...bool) error {
+	count := 0
+	for _, set := range alternatives {
+		if set {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("expecting exactly one alternative, got %d", count)
+	}
+	return nil
+}
+
+type expressionJSON struct {
+	Pos        positionJSON `json:"pos"`
+	Let        *Let         `json:"let,omitempty"`
+	Comparison *Comparison  `json:"comparison,omitempty"`
+	Op         *string      `json:"op,omitempty"`
+	Next       *Expression  `json:"next,omitempty"`
+}
+
+// MarshalJSON encodes an expression as JSON
+func (e *Expression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(expressionJSON{
+		Pos:        newPositionJSON(e.Pos),
+		Let:        e.Let,
+		Comparison: e.Comparison,
+		Op:         opJSON(e.Op),
+		Next:       e.Next,
+	})
+}
+
+// UnmarshalJSON decodes an expression from JSON
+func (e *Expression) UnmarshalJSON(data []byte) error {
+	var v expressionJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	op, err := parseOpJSON(v.Op, booleanOps...)
+	switch {
+	case err != nil:
+	case v.Let != nil:
+		if v.Comparison != nil || op != nil || v.Next != nil {
+			err = errors.New("unexpected comparison or operator along with let")
+		}
+	case v.Comparison == nil:
+		err = errors.New("missing comparison")
+	default:
+		err = checkPair(op, v.Next != nil)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid expression: %s", err)
+	}
+	*e = Expression{Pos: v.Pos.position(), Let: v.Let, Comparison: v.Comparison, Op: op, Next: v.Next}
+	return nil
+}
+
+type letJSON struct {
+	Pos      positionJSON `json:"pos"`
+	Bindings []*Binding   `json:"bindings"`
+	Body     *Expression  `json:"body"`
+}
+
+// MarshalJSON encodes a let expression as JSON
+func (l *Let) MarshalJSON() ([]byte, error) {
+	return json.Marshal(letJSON{
+		Pos:      newPositionJSON(l.Pos),
+		Bindings: l.Bindings,
+		Body:     l.Body,
+	})
+}
+
+// UnmarshalJSON decodes a let expression from JSON
+func (l *Let) UnmarshalJSON(data []byte) error {
+	var v letJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	if len(v.Bindings) == 0 {
+		return errors.New("invalid let: missing bindings")
+	}
+	for _, binding := range v.Bindings {
+		if binding == nil {
+			return errors.New("invalid let: missing binding")
+		}
+	}
+	if v.Body == nil {
+		return errors.New("invalid let: missing body")
+	}
+	*l = Let{Pos: v.Pos.position(), Bindings: v.Bindings, Body: v.Body}
+	return nil
+}
+
+type bindingJSON struct {
+	Pos   positionJSON `json:"pos"`
+	Name  string       `json:"name"`
+	Value *Term        `json:"value"`
+}
+
+// MarshalJSON encodes a binding as JSON
+func (b *Binding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bindingJSON{
+		Pos:   newPositionJSON(b.Pos),
+		Name:  b.Name,
+		Value: b.Value,
+	})
+}
+
+// UnmarshalJSON decodes a binding from JSON
+func (b *Binding) UnmarshalJSON(data []byte) error {
+	var v bindingJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	if v.Name == "" {
+		return errors.New("invalid binding: missing variable name")
+	}
+	if v.Value == nil {
+		return fmt.Errorf("invalid binding of %s: missing value", v.Name)
+	}
+	*b = Binding{Pos: v.Pos.position(), Name: v.Name, Value: v.Value}
+	return nil
+}
+
+type iterableExpressionJSON struct {
+	Pos                positionJSON        `json:"pos"`
+	IterableComparison *IterableComparison `json:"iterableComparison,omitempty"`
+	Expression         *Expression         `json:"expression,omitempty"`
+}
+
+// MarshalJSON encodes an iterable expression as JSON
+func (e *IterableExpression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(iterableExpressionJSON{
+		Pos:                newPositionJSON(e.Pos),
+		IterableComparison: e.IterableComparison,
+		Expression:         e.Expression,
+	})
+}
+
+// UnmarshalJSON decodes an iterable expression from JSON
+func (e *IterableExpression) UnmarshalJSON(data []byte) error {
+	var v iterableExpressionJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	if err := checkOneOf(v.IterableComparison != nil, v.Expression != nil); err != nil {
+		return fmt.Errorf("invalid iterable expression: %s", err)
+	}
+	*e = IterableExpression{Pos: v.Pos.position(), IterableComparison: v.IterableComparison, Expression: v.Expression}
+	return nil
+}
+
+type iterableComparisonJSON struct {
+	Pos              positionJSON      `json:"pos"`
+	Fn               *string           `json:"fn"`
+	Expression       *Expression       `json:"expression"`
+	ScalarComparison *ScalarComparison `json:"scalarComparison,omitempty"`
+}
+
+// MarshalJSON encodes an iterable comparison as JSON
+func (c *IterableComparison) MarshalJSON() ([]byte, error) {
+	return json.Marshal(iterableComparisonJSON{
+		Pos:              newPositionJSON(c.Pos),
+		Fn:               c.Fn,
+		Expression:       c.Expression,
+		ScalarComparison: c.ScalarComparison,
+	})
+}
+
+// UnmarshalJSON decodes an iterable comparison from JSON
+func (c *IterableComparison) UnmarshalJSON(data []byte) error {
+	var v iterableComparisonJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	switch {
+	case v.Fn == nil || *v.Fn == "":
+		return errors.New("invalid iterable comparison: missing function")
+	case v.Expression == nil:
+		return errors.New("invalid iterable comparison: missing expression")
+	}
+	*c = IterableComparison{Pos: v.Pos.position(), Fn: v.Fn, Expression: v.Expression, ScalarComparison: v.ScalarComparison}
+	return nil
+}
+
+type pathExpressionJSON struct {
+	Pos        positionJSON `json:"pos"`
+	Path       *string      `json:"path,omitempty"`
+	Expression *Expression  `json:"expression,omitempty"`
+}
+
+// MarshalJSON encodes a path expression as JSON
+func (e *PathExpression) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pathExpressionJSON{
+		Pos:        newPositionJSON(e.Pos),
+		Path:       e.Path,
+		Expression: e.Expression,
+	})
+}
+
+// UnmarshalJSON decodes a path expression from JSON
+func (e *PathExpression) UnmarshalJSON(data []byte) error {
+	var v pathExpressionJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	if err := checkOneOf(v.Path != nil, v.Expression != nil); err != nil {
+		return fmt.Errorf("invalid path expression: %s", err)
+	}
+	*e = PathExpression{Pos: v.Pos.position(), Path: v.Path, Expression: v.Expression}
+	return nil
+}
+
+type comparisonJSON struct {
+	Pos              positionJSON      `json:"pos"`
+	Values           []Value           `json:"values,omitempty"`
+	Term             *Term             `json:"term,omitempty"`
+	ScalarComparison *ScalarComparison `json:"scalarComparison,omitempty"`
+	ArrayComparison  *ArrayComparison  `json:"arrayComparison,omitempty"`
+}
+
+// MarshalJSON encodes a comparison as JSON
+func (c *Comparison) MarshalJSON() ([]byte, error) {
+	return json.Marshal(comparisonJSON{
+		Pos:              newPositionJSON(c.Pos),
+		Values:           c.Values,
+		Term:             c.Term,
+		ScalarComparison: c.ScalarComparison,
+		ArrayComparison:  c.ArrayComparison,
+	})
+}
+
+// UnmarshalJSON decodes a comparison from JSON
+func (c *Comparison) UnmarshalJSON(data []byte) error {
+	var v comparisonJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	switch {
+	case v.Term == nil && len(v.Values) == 0:
+		return errors.New("invalid comparison: missing term")
+	case v.Term != nil && len(v.Values) != 0:
+		return errors.New("invalid comparison: expecting either term or values")
+	case v.ScalarComparison != nil && v.ArrayComparison != nil:
+		return errors.New("invalid comparison: expecting either scalar or array comparison")
+	}
+	*c = Comparison{Pos: v.Pos.position(), Values: v.Values, Term: v.Term, ScalarComparison: v.ScalarComparison, ArrayComparison: v.ArrayComparison}
+	return nil
+}
+
+type scalarComparisonJSON struct {
+	Pos  positionJSON `json:"pos"`
+	Op   *string      `json:"op"`
+	Next *Comparison  `json:"next"`
+}
+
+// MarshalJSON encodes a scalar comparison as JSON
+func (c *ScalarComparison) MarshalJSON() ([]byte, error) {
+	return json.Marshal(scalarComparisonJSON{
+		Pos:  newPositionJSON(c.Pos),
+		Op:   opJSON(c.Op),
+		Next: c.Next,
+	})
+}
+
+// UnmarshalJSON decodes a scalar comparison from JSON
+func (c *ScalarComparison) UnmarshalJSON(data []byte) error {
+	var v scalarComparisonJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	op, err := parseOpJSON(v.Op, scalarOps...)
+	if err == nil && op == nil {
+		err = errors.New("missing operator")
+	}
+	if err == nil && v.Next == nil {
+		err = fmt.Errorf("missing rhs of %s", *op)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid scalar comparison: %s", err)
+	}
+	*c = ScalarComparison{Pos: v.Pos.position(), Op: op, Next: v.Next}
+	return nil
+}
+
+type arrayComparisonJSON struct {
+	Pos   positionJSON `json:"pos"`
+	Op    *string      `json:"op"`
+	Array *Array       `json:"array"`
+}
+
+// MarshalJSON encodes an array comparison as JSON
+func (c *ArrayComparison) MarshalJSON() ([]byte, error) {
+	return json.Marshal(arrayComparisonJSON{
+		Pos:   newPositionJSON(c.Pos),
+		Op:    opJSON(c.Op),
+		Array: c.Array,
+	})
+}
+
+// UnmarshalJSON decodes an array comparison from JSON
+func (c *ArrayComparison) UnmarshalJSON(data []byte) error {
+	var v arrayComparisonJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	op, err := parseOpJSON(v.Op, arrayOps...)
+	if err == nil && op == nil {
+		err = errors.New("missing operator")
+	}
+	if err == nil && v.Array == nil {
+		err = fmt.Errorf("missing rhs of %s", *op)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid array comparison: %s", err)
+	}
+	*c = ArrayComparison{Pos: v.Pos.position(), Op: op, Array: v.Array}
+	return nil
+}
+
+type termJSON struct {
+	Pos   positionJSON `json:"pos"`
+	Unary *Unary       `json:"unary"`
+	Op    *string      `json:"op,omitempty"`
+	Next  *Term        `json:"next,omitempty"`
+}
+
+// MarshalJSON encodes a term as JSON
+func (t *Term) MarshalJSON() ([]byte, error) {
+	return json.Marshal(termJSON{
+		Pos:   newPositionJSON(t.Pos),
+		Unary: t.Unary,
+		Op:    t.Op,
+		Next:  t.Next,
+	})
+}
+
+// UnmarshalJSON decodes a term from JSON
+func (t *Term) UnmarshalJSON(data []byte) error {
+	var v termJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	op, err := parseOpJSON(v.Op, termOps...)
+	if err == nil && v.Unary == nil {
+		err = errors.New("missing unary")
+	}
+	if err == nil {
+		err = checkPair(op, v.Next != nil)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid term: %s", err)
+	}
+	*t = Term{Pos: v.Pos.position(), Unary: v.Unary, Op: op, Next: v.Next}
+	return nil
+}
+
+type unaryJSON struct {
+	Pos   positionJSON `json:"pos"`
+	Op    *string      `json:"op,omitempty"`
+	Unary *Unary       `json:"unary,omitempty"`
+	Value *Value       `json:"value,omitempty"`
+}
+
+// MarshalJSON encodes a unary operation as JSON
+func (u *Unary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(unaryJSON{
+		Pos:   newPositionJSON(u.Pos),
+		Op:    u.Op,
+		Unary: u.Unary,
+		Value: u.Value,
+	})
+}
+
+// UnmarshalJSON decodes a unary operation from JSON
+func (u *Unary) UnmarshalJSON(data []byte) error {
+	var v unaryJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	op, err := parseOpJSON(v.Op, unaryOps...)
+	if err == nil {
+		err = checkOneOf(v.Unary != nil, v.Value != nil)
+	}
+	if err == nil {
+		err = checkPair(op, v.Unary != nil)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid unary: %s", err)
+	}
+	*u = Unary{Pos: v.Pos.position(), Op: op, Unary: v.Unary, Value: v.Value}
+	return nil
+}
+
+type arrayJSON struct {
+	Pos    positionJSON `json:"pos"`
+	Values []Value      `json:"values,omitempty"`
+	Call   *Call        `json:"call,omitempty"`
+	Ident  *string      `json:"ident,omitempty"`
+}
+
+// MarshalJSON encodes an array as JSON
+func (a *Array) MarshalJSON() ([]byte, error) {
+	return json.Marshal(arrayJSON{
+		Pos:    newPositionJSON(a.Pos),
+		Values: a.Values,
+		Call:   a.Call,
+		Ident:  a.Ident,
+	})
+}
+
+// UnmarshalJSON decodes an array from JSON
+func (a *Array) UnmarshalJSON(data []byte) error {
+	var v arrayJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	if err := checkOneOf(len(v.Values) > 0, v.Call != nil, v.Ident != nil); err != nil {
+		return fmt.Errorf("invalid array: %s", err)
+	}
+	*a = Array{Pos: v.Pos.position(), Values: v.Values, Call: v.Call, Ident: v.Ident}
+	return nil
+}
+
+type valueJSON struct {
+	Pos           positionJSON `json:"pos"`
+	Size          *int64       `json:"size,omitempty"`
+	Duration      *int64       `json:"duration,omitempty"`
+	Hex           *string      `json:"hex,omitempty"`
+	Octal         *string      `json:"octal,omitempty"`
+	Decimal       *int64       `json:"decimal,omitempty"`
+	String        *string      `json:"string,omitempty"`
+	Call          *Call        `json:"call,omitempty"`
+	Variable      *string      `json:"variable,omitempty"`
+	Subexpression *Expression  `json:"subexpression,omitempty"`
+}
+
+// MarshalJSON encodes a value as JSON, where sizes are in bytes and durations in nanoseconds
+func (v *Value) MarshalJSON() ([]byte, error) {
+	encoded := valueJSON{
+		Pos:           newPositionJSON(v.Pos),
+		Hex:           v.Hex,
+		Octal:         v.Octal,
+		Decimal:       v.Decimal,
+		String:        v.String,
+		Call:          v.Call,
+		Variable:      v.Variable,
+		Subexpression: v.Subexpression,
+	}
+	if v.Size != nil {
+		size := int64(*v.Size)
+		encoded.Size = &size
+	}
+	if v.Duration != nil {
+		duration := int64(*v.Duration)
+		encoded.Duration = &duration
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes a value from JSON
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var encoded valueJSON
+	if err := decodeStrict(data, &encoded); err != nil {
+		return err
+	}
+	err := checkOneOf(
+		encoded.Size != nil,
+		encoded.Duration != nil,
+		encoded.Hex != nil,
+		encoded.Octal != nil,
+		encoded.Decimal != nil,
+		encoded.String != nil,
+		encoded.Call != nil,
+		encoded.Variable != nil,
+		encoded.Subexpression != nil,
+	)
+	if err == nil && encoded.Hex != nil {
+		if _, parseErr := strconv.ParseUint(*encoded.Hex, 0, 64); parseErr != nil || !strings.HasPrefix(*encoded.Hex, "0x") {
+			err = fmt.Errorf("invalid hex %q", *encoded.Hex)
+		}
+	}
+	if err == nil && encoded.Octal != nil {
+		if _, parseErr := strconv.ParseUint(*encoded.Octal, 8, 64); parseErr != nil || !strings.HasPrefix(*encoded.Octal, "0") {
+			err = fmt.Errorf("invalid octal %q", *encoded.Octal)
+		}
+	}
+	if err == nil && encoded.Variable != nil && *encoded.Variable == "" {
+		err = errors.New("empty variable name")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value: %s", err)
+	}
+
+	*v = Value{
+		Pos:           encoded.Pos.position(),
+		Hex:           encoded.Hex,
+		Octal:         encoded.Octal,
+		Decimal:       encoded.Decimal,
+		String:        encoded.String,
+		Call:          encoded.Call,
+		Variable:      encoded.Variable,
+		Subexpression: encoded.Subexpression,
+	}
+	if encoded.Size != nil {
+		size := Size(*encoded.Size)
+		v.Size = &size
+	}
+	if encoded.Duration != nil {
+		duration := Duration(time.Duration(*encoded.Duration))
+		v.Duration = &duration
+	}
+	return nil
+}
+
+type callJSON struct {
+	Pos  positionJSON  `json:"pos"`
+	Name string        `json:"name"`
+	Args []*Expression `json:"args"`
+}
+
+// MarshalJSON encodes a function call as JSON
+func (c *Call) MarshalJSON() ([]byte, error) {
+	args := c.Args
+	if args == nil {
+		args = []*Expression{}
+	}
+	return json.Marshal(callJSON{
+		Pos:  newPositionJSON(c.Pos),
+		Name: c.Name,
+		Args: args,
+	})
+}
+
+// UnmarshalJSON decodes a function call from JSON
+func (c *Call) UnmarshalJSON(data []byte) error {
+	var v callJSON
+	if err := decodeStrict(data, &v); err != nil {
+		return err
+	}
+	if v.Name == "" {
+		return errors.New("invalid call: missing function name")
+	}
+	for _, arg := range v.Args {
+		if arg == nil {
+			return fmt.Errorf("invalid call of %s: missing argument", v.Name)
+		}
+	}
+	var args []*Expression
+	if len(v.Args) > 0 {
+		args = v.Args
+	}
+	*c = Call{Pos: v.Pos.position(), Name: v.Name, Args: args}
+	return nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestMarshalNodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		parse      func(string) (Node, error)
+		expression string
+	}{
+		{
+			name:       "expression",
+			parse:      func(s string) (Node, error) { return ParseExpression(s) },
+			expression: `file.owner == "root" && (file.permissions & 022) == 0 || !f() && -x < ^0x1F`,
+		},
+		{
+			name:       "operators",
+			parse:      func(s string) (Node, error) { return ParseExpression(s) },
+			expression: `a not in [1, 0644, "b"] && b all of groups(x, y) && c any of d && e not like "f%" && g ==~ "h" && i not contains 'j'`,
+		},
+		{
+			name:       "array literal lhs",
+			parse:      func(s string) (Node, error) { return ParseExpression(s) },
+			expression: `[1, a] any of [f(b), 2]`,
+		},
+		{
+			name:       "literals",
+			parse:      func(s string) (Node, error) { return ParseExpression(s) },
+			expression: `size < 512MiB && age > 1h30m && delta >= -5s && n in [0, -12]`,
+		},
+		{
+			name:       "let",
+			parse:      func(s string) (Node, error) { return ParseExpression(s) },
+			expression: `let x = f("a"), y = (x == 1) in y && (let z = x | 2 in z == 3)`,
+		},
+		{
+			name:       "iterable",
+			parse:      func(s string) (Node, error) { return ParseIterable(s) },
+			expression: `len(process.name == "sshd") > 2`,
+		},
+		{
+			name:       "iterable expression",
+			parse:      func(s string) (Node, error) { return ParseIterable(s) },
+			expression: `process.name == "sshd"`,
+		},
+		{
+			name:       "path",
+			parse:      func(s string) (Node, error) { return ParsePath(s) },
+			expression: `/etc/ssh/*_config`,
+		},
+		{
+			name:       "path expression",
+			parse:      func(s string) (Node, error) { return ParsePath(s) },
+			expression: `"/home/" + user`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			node, err := test.parse(test.expression)
+			assert.NoError(err)
+
+			data, err := MarshalNode(node)
+			assert.NoError(err)
+
+			decoded, err := UnmarshalNode(data)
+			assert.NoError(err)
+			assert.Equal(node, decoded)
+			assert.Equal(Format(node), Format(decoded))
+		})
+	}
+}
+
+func TestMarshalNodeEncoding(t *testing.T) {
+	assert := assert.New(t)
+	expr, err := ParseExpression(`a not in [1h]`)
+	assert.NoError(err)
+
+	data, err := MarshalNode(expr)
+	assert.NoError(err)
+	assert.JSONEq(`{
+		"version": 1,
+		"type": "expression",
+		"node": {
+			"pos": {"offset": 0, "line": 1, "column": 1},
+			"comparison": {
+				"pos": {"offset": 0, "line": 1, "column": 1},
+				"term": {
+					"pos": {"offset": 0, "line": 1, "column": 1},
+					"unary": {
+						"pos": {"offset": 0, "line": 1, "column": 1},
+						"value": {
+							"pos": {"offset": 0, "line": 1, "column": 1},
+							"variable": "a"
+						}
+					}
+				},
+				"arrayComparison": {
+					"pos": {"offset": 2, "line": 1, "column": 3},
+					"op": "not in",
+					"array": {
+						"pos": {"offset": 9, "line": 1, "column": 10},
+						"values": [
+							{
+								"pos": {"offset": 10, "line": 1, "column": 11},
+								"duration": 3600000000000
+							}
+						]
+					}
+				}
+			}
+		}
+	}`, string(data))
+}
+
+func TestUnmarshalNodeEvaluate(t *testing.T) {
+	assert := assert.New(t)
+	decoded, err := UnmarshalNode([]byte(`{
+		"version": 1,
+		"type": "expression",
+		"node": {
+			"pos": {"offset": 0, "line": 1, "column": 1},
+			"comparison": {
+				"pos": {"offset": 0, "line": 1, "column": 1},
+				"term": {"pos": {"offset": 0, "line": 1, "column": 1}, "unary": {"pos": {"offset": 0, "line": 1, "column": 1}, "value": {"pos": {"offset": 0, "line": 1, "column": 1}, "variable": "x"}}},
+				"scalarComparison": {
+					"pos": {"offset": 2, "line": 1, "column": 3},
+					"op": "==",
+					"next": {"pos": {"offset": 5, "line": 1, "column": 6}, "term": {"pos": {"offset": 5, "line": 1, "column": 6}, "unary": {"pos": {"offset": 5, "line": 1, "column": 6}, "value": {"pos": {"offset": 5, "line": 1, "column": 6}, "size": 1024}}}}
+				}
+			}
+		}
+	}`))
+	assert.NoError(err)
+	assert.Equal(`x == 1KiB`, Format(decoded))
+
+	expr, ok := decoded.(*Expression)
+	assert.True(ok)
+	result, err := expr.Evaluate(&Instance{Vars: VarMap{"x": 1024}})
+	assert.NoError(err)
+	assert.Equal(true, result)
+
+	_, err = expr.Evaluate(&Instance{})
+	assert.EqualError(err, `1:1: unknown variable "x"`)
+}
+
+func TestUnmarshalNodeErrors(t *testing.T) {
+	value := func(s string) string {
+		return `{"version": 1, "type": "value", "node": ` + s + `}`
+	}
+
+	tests := []struct {
+		name        string
+		data        string
+		expectError string
+	}{
+		{
+			name:        "invalid json",
+			data:        `{`,
+			expectError: "unexpected EOF",
+		},
+		{
+			name:        "unsupported version",
+			data:        `{"version": 2, "type": "expression", "node": {}}`,
+			expectError: "unsupported syntax tree version 2",
+		},
+		{
+			name:        "unknown type",
+			data:        `{"version": 1, "type": "statement", "node": {}}`,
+			expectError: `unknown node type "statement"`,
+		},
+		{
+			name:        "missing node",
+			data:        `{"version": 1, "type": "expression"}`,
+			expectError: "missing node",
+		},
+		{
+			name:        "unknown field",
+			data:        value(`{"variable": "x", "constant": true}`),
+			expectError: `json: unknown field "constant"`,
+		},
+		{
+			name:        "several alternatives",
+			data:        value(`{"variable": "x", "decimal": 1}`),
+			expectError: "invalid value: expecting exactly one alternative, got 2",
+		},
+		{
+			name:        "no alternatives",
+			data:        value(`{}`),
+			expectError: "invalid value: expecting exactly one alternative, got 0",
+		},
+		{
+			name:        "invalid octal",
+			data:        value(`{"octal": "0999"}`),
+			expectError: `invalid value: invalid octal "0999"`,
+		},
+		{
+			name:        "invalid hex",
+			data:        value(`{"hex": "1F"}`),
+			expectError: `invalid value: invalid hex "1F"`,
+		},
+		{
+			name:        "invalid operator",
+			data:        `{"version": 1, "type": "scalarComparison", "node": {"op": "<>", "next": {"term": {"unary": {"value": {"decimal": 1}}}}}}`,
+			expectError: `invalid scalar comparison: invalid operator "<>"`,
+		},
+		{
+			name:        "missing rhs",
+			data:        `{"version": 1, "type": "expression", "node": {"comparison": {"term": {"unary": {"value": {"decimal": 1}}}}, "op": "&&"}}`,
+			expectError: "invalid expression: missing rhs of &&",
+		},
+		{
+			name:        "nested error",
+			data:        `{"version": 1, "type": "expression", "node": {"comparison": {"term": {"unary": {"op": "!"}}}}}`,
+			expectError: "invalid unary: expecting exactly one alternative, got 0",
+		},
+		{
+			name:        "missing call argument",
+			data:        `{"version": 1, "type": "call", "node": {"name": "f", "args": [null]}}`,
+			expectError: "invalid call of f: missing argument",
+		},
+		{
+			name:        "let with operator",
+			data:        `{"version": 1, "type": "expression", "node": {"let": {"bindings": [{"name": "x", "value": {"unary": {"value": {"decimal": 1}}}}], "body": {"comparison": {"term": {"unary": {"value": {"variable": "x"}}}}}}, "op": "&&"}}`,
+			expectError: "invalid expression: unexpected comparison or operator along with let",
+		},
+		{
+			name:        "let without bindings",
+			data:        `{"version": 1, "type": "let", "node": {"bindings": [], "body": {"comparison": {"term": {"unary": {"value": {"decimal": 1}}}}}}}`,
+			expectError: "invalid let: missing bindings",
+		},
+		{
+			name:        "binding without value",
+			data:        `{"version": 1, "type": "binding", "node": {"name": "x"}}`,
+			expectError: "invalid binding of x: missing value",
+		},
+		{
+			name:        "empty array",
+			data:        `{"version": 1, "type": "array", "node": {"values": []}}`,
+			expectError: "invalid array: expecting exactly one alternative, got 0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			node, err := UnmarshalNode([]byte(test.data))
+			assert.Nil(node)
+			assert.EqualError(err, test.expectError)
+		})
+	}
+}
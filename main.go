@@ -1,5 +1,606 @@
 package main
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/repr"
+	"gopkg.in/yaml.v2"
+)
+
+const usage = `Usage:
+  expressionist eval [--vars FILE]... [--var NAME=VALUE]... EXPRESSION
+  expressionist parse EXPRESSION
+  expressionist check [--vars FILE]... [--var NAME=VALUE]... RULES
+  expressionist repl [--vars FILE]... [--var NAME=VALUE]... [--history FILE]
+  expressionist fmt [-w] RULES
+
+Commands:
+  eval   evaluates an expression and prints the result
+  parse  prints the syntax tree of an expression
+  check  evaluates rules of a JSON or YAML file and reports failing ones
+  repl   evaluates expressions read line by line interactively
+  fmt    formats expressions of a rules file, rewriting it with -w
+
+Variables are read from JSON or YAML files, where nested maps are flattened
+to dotted names, e.g. {"file": {"owner": "root"}} defines file.owner.
+
+Expressions starting with a dash follow "--" to tell them apart from flags,
+e.g. expressionist eval -- '-1KiB < 0'.
+
+Exit status is 0 on success, 1 when an expression evaluates to false or a
+rule fails and 2 on invalid usage, syntax or evaluation errors, including
+errors of any rule.
+`
+
+const (
+	exitPassed = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes a command with arguments and returns the exit status
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+
+	var err error
+	status := exitPassed
+	switch command, args := args[0], args[1:]; command {
+	case "eval":
+		status, err = runEval(args, stdout, stderr)
+	case "parse":
+		status, err = runParse(args, stdout, stderr)
+	case "check":
+		status, err = runCheck(args, stdout, stderr)
+	case "repl":
+		status, err = runREPL(args, stdin, stdout, stderr)
+	case "fmt":
+		status, err = runFmt(args, stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+	default:
+		err = fmt.Errorf("unknown command %q", command)
+	}
+	if err == flag.ErrHelp {
+		return exitPassed
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "expressionist: %s\n", err)
+		return exitError
+	}
+	return status
+}
+
+func runEval(args []string, stdout, stderr io.Writer) (int, error) {
+	flags, vars := newVarsFlagSet("eval", stderr)
+	expression, err := parseCommandLine(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+	instance, err := vars.instance()
+	if err != nil {
+		return exitError, err
+	}
+
+	expr, err := ParseExpression(expression)
+	if err != nil {
+		return exitError, err
+	}
+	result, err := expr.Evaluate(instance)
+	if err != nil {
+		return exitError, err
+	}
+
+	fmt.Fprintln(stdout, result)
+	if result == false {
+		return exitFailed, nil
+	}
+	return exitPassed, nil
+}
+
+func runParse(args []string, stdout, stderr io.Writer) (int, error) {
+	flags := flag.NewFlagSet("parse", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	expression, err := parseCommandLine(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+
+	expr, err := ParseExpression(expression)
+	if err != nil {
+		return exitError, err
+	}
+	fmt.Fprintln(stdout, repr.String(expr, repr.Indent("  "), repr.OmitEmpty(true)))
+	return exitPassed, nil
+}
+
+// Rule is an expression of a rules file along with its own variables and expected result
+type Rule struct {
+	Name       string                 `yaml:"name"`
+	Expression string                 `yaml:"expression"`
+	Vars       map[string]interface{} `yaml:"vars"`
+	Expect     *bool                  `yaml:"expect"`
+}
+
+// RuleFile is a list of rules sharing common variables
+type RuleFile struct {
+	Vars  map[string]interface{} `yaml:"vars"`
+	Rules []Rule                 `yaml:"rules"`
+}
+
+func runCheck(args []string, stdout, stderr io.Writer) (int, error) {
+	flags, vars := newVarsFlagSet("check", stderr)
+	filename, err := parseCommandLine(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return exitError, err
+	}
+	var file RuleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return exitError, fmt.Errorf("invalid rules file %s: %s", filename, err)
+	}
+
+	instance, err := vars.instance()
+	if err != nil {
+		return exitError, err
+	}
+	shared := VarMap{}
+	flattenVars("", file.Vars, shared)
+
+	status := exitPassed
+	for i, rule := range file.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule #%d", i+1)
+		}
+
+		ruleInstance := &Instance{
+			Functions: instance.Functions,
+			Vars:      VarMap{},
+		}
+		for _, vars := range []VarMap{shared, instance.Vars} {
+			for k, v := range vars {
+				ruleInstance.Vars[k] = v
+			}
+		}
+		flattenVars("", rule.Vars, ruleInstance.Vars)
+
+		failure, err := checkRule(rule, ruleInstance)
+		switch {
+		case err != nil:
+			// Errors take precedence over failures in the exit status
+			fmt.Fprintf(stdout, "ERROR %s: %s\n", name, err)
+			status = exitError
+		case failure != "":
+			fmt.Fprintf(stdout, "FAIL %s: %s\n", name, failure)
+			if status == exitPassed {
+				status = exitFailed
+			}
+		default:
+			fmt.Fprintf(stdout, "PASS %s\n", name)
+		}
+	}
+	return status, nil
+}
+
+// checkRule evaluates a rule and describes why it fails, or returns syntax and evaluation errors
+func checkRule(rule Rule, instance *Instance) (string, error) {
+	expr, err := ParseExpression(rule.Expression)
+	if err != nil {
+		return "", err
+	}
+	result, err := expr.Evaluate(instance)
+	if err != nil {
+		return "", err
+	}
+
+	expect := true
+	if rule.Expect != nil {
+		expect = *rule.Expect
+	}
+	if result != expect {
+		return fmt.Sprintf("expected %v, got %v", expect, result), nil
+	}
+	return "", nil
+}
+
+func runFmt(args []string, stdout, stderr io.Writer) (int, error) {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result to the rules file instead of standard output")
+	filename, err := parseCommandLine(flags, args)
+	if err != nil {
+		return exitError, err
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return exitError, err
+	}
+	formatted, err := formatRules(data)
+	if err != nil {
+		return exitError, fmt.Errorf("invalid rules file %s: %s", filename, err)
+	}
+
+	if !*write {
+		_, err := stdout.Write(formatted)
+		return exitPassed, err
+	}
+	if bytes.Equal(data, formatted) {
+		return exitPassed, nil
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return exitError, err
+	}
+	return exitPassed, ioutil.WriteFile(filename, formatted, info.Mode())
+}
+
+// formatRules formats expressions of rules in a rules file. Only expression values are rewritten,
+// keeping their quoting style, so that comments, layout and JSON syntax of the file are preserved.
+func formatRules(data []byte) ([]byte, error) {
+	var file yaml.MapSlice
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	var expressions []string
+	for _, item := range file {
+		if item.Key != "rules" {
+			continue
+		}
+		rules, ok := item.Value.([]interface{})
+		if !ok {
+			return nil, errors.New("rules must be a list")
+		}
+		for i, rule := range rules {
+			rule, ok := rule.(yaml.MapSlice)
+			if !ok {
+				return nil, fmt.Errorf("rule #%d must be a map", i+1)
+			}
+			for j := range rule {
+				if rule[j].Key != "expression" {
+					continue
+				}
+				expression, ok := rule[j].Value.(string)
+				if !ok {
+					return nil, fmt.Errorf("expression of rule #%d must be a string", i+1)
+				}
+				if _, err := ParseExpression(expression); err != nil {
+					return nil, fmt.Errorf("rule #%d: %s", i+1, err)
+				}
+				expressions = append(expressions, expression)
+			}
+		}
+	}
+
+	var (
+		formatted bytes.Buffer
+		offset    int
+	)
+	for _, loc := range expressionKeyRegexp.FindAllIndex(data, -1) {
+		if len(expressions) == 0 {
+			break
+		}
+		if loc[0] < offset {
+			continue
+		}
+		scalar := scanScalar(data, loc[1])
+		value, ok := decodeScalar(data[loc[1]:scalar])
+		if !ok || value != expressions[0] {
+			continue
+		}
+		expr, err := ParseExpression(expressions[0])
+		if err != nil {
+			return nil, err
+		}
+		formatted.Write(data[offset:loc[1]])
+		formatted.WriteString(encodeScalar(data[loc[1]:scalar], Format(expr)))
+		offset = scalar
+		expressions = expressions[1:]
+	}
+	if len(expressions) != 0 {
+		return nil, fmt.Errorf("failed to locate expression %q", expressions[0])
+	}
+	formatted.Write(data[offset:])
+	return formatted.Bytes(), nil
+}
+
+// expressionKeyRegexp matches expression keys of rules up to their values
+var expressionKeyRegexp = regexp.MustCompile(`(?:"expression"|'expression'|\bexpression)[ \t]*:[ \t]*`)
+
+// scanScalar returns the end of a YAML or JSON scalar value starting at offset
+func scanScalar(data []byte, offset int) int {
+	if offset >= len(data) {
+		return offset
+	}
+	switch data[offset] {
+	case '"':
+		for i := offset + 1; i < len(data); i++ {
+			switch data[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+	case '\'':
+		for i := offset + 1; i < len(data); i++ {
+			if data[i] == '\'' {
+				if i+1 < len(data) && data[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	case '|', '>':
+		// Block scalars end before the first non-empty line indented less than their contents
+		end := lineEnd(data, offset)
+		indent := -1
+		for next := end; next < len(data); {
+			line := data[next+1 : lineEnd(data, next+1)]
+			next = lineEnd(data, next+1)
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			n := len(line) - len(bytes.TrimLeft(line, " "))
+			if indent == -1 {
+				indent = n
+			}
+			if n < indent || n == 0 {
+				break
+			}
+			end = next
+		}
+		return end
+	default:
+		end := lineEnd(data, offset)
+		if comment := bytes.Index(data[offset:end], []byte(" #")); comment != -1 {
+			end = offset + comment
+		}
+		return offset + len(bytes.TrimRight(data[offset:end], " \t\r"))
+	}
+	return len(data)
+}
+
+// lineEnd returns the offset of the end of line containing offset
+func lineEnd(data []byte, offset int) int {
+	if offset >= len(data) {
+		return len(data)
+	}
+	if i := bytes.IndexByte(data[offset:], '\n'); i != -1 {
+		return offset + i
+	}
+	return len(data)
+}
+
+// decodeScalar decodes a YAML or JSON scalar value as a string
+func decodeScalar(scalar []byte) (string, bool) {
+	var value map[string]interface{}
+	if err := yaml.Unmarshal(append([]byte("value: "), scalar...), &value); err != nil {
+		return "", false
+	}
+	s, ok := value["value"].(string)
+	return s, ok
+}
+
+// encodeScalar encodes a string in the style of a scalar value it replaces, falling back to
+// double quotes when the style can't represent the string
+func encodeScalar(scalar []byte, s string) string {
+	var encoded string
+	switch scalar[0] {
+	case '"':
+		encoded = quoteScalar(s)
+	case '\'':
+		encoded = "'" + strings.Replace(s, "'", "''", -1) + "'"
+	case '|', '>':
+		header := scalar[:lineEnd(scalar, 0)]
+		lines := scalar[len(header):]
+		indent := len(lines) - len(bytes.TrimLeft(lines, "\r\n"))
+		indent = len(lines[indent:]) - len(bytes.TrimLeft(lines[indent:], " "))
+		encoded = string(header) + "\n" + strings.Repeat(" ", indent) + s
+		if value, ok := decodeScalar([]byte(encoded)); ok && strings.TrimRight(value, "\n") == s {
+			return encoded
+		}
+		return quoteScalar(s)
+	default:
+		encoded = s
+	}
+	if value, ok := decodeScalar([]byte(encoded)); ok && value == s {
+		return encoded
+	}
+	return quoteScalar(s)
+}
+
+// quoteScalar encodes a string as a double-quoted scalar, which is valid both in JSON and YAML
+func quoteScalar(s string) string {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// parseCommandLine parses flags interspersed with a single positional argument, where arguments
+// following "--" are positional even when starting with a dash
+func parseCommandLine(flags *flag.FlagSet, args []string) (string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return "", err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		if n := len(args) - flags.NArg(); n > 0 && args[n-1] == "--" {
+			positional = append(positional, flags.Args()...)
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(positional) != 1 {
+		return "", fmt.Errorf("%s expects exactly one argument", flags.Name())
+	}
+	return positional[0], nil
+}
+
+// varsFlags collects variables files and variables set on the command line
+type varsFlags struct {
+	files  stringsFlag
+	values stringsFlag
+}
+
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func newVarsFlagSet(name string, stderr io.Writer) (*flag.FlagSet, *varsFlags) {
+	vars := &varsFlags{}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Var(&vars.files, "vars", "read variables from a JSON or YAML `file`")
+	flags.Var(&vars.values, "var", "set a variable using `name=value`, where value is parsed as YAML")
+	return flags, vars
+}
+
+// instance returns an instance with variables of the flags and all the functions available
+func (f *varsFlags) instance() (*Instance, error) {
+	instance := &Instance{
+		Functions: FunctionMap{},
+		Vars:      VarMap{},
+	}
+	for _, functions := range []FunctionMap{
+		FileFunctions(HostFileSystem),
+		StringFunctions(),
+		VersionFunctions(),
+		TimeFunctions(),
+		NetworkFunctions(),
+	} {
+		for name, fn := range functions {
+			instance.Functions[name] = fn
+		}
+	}
+
+	for _, filename := range f.files {
+		if err := loadVars(filename, instance.Vars); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, value := range f.values {
+		i := strings.IndexByte(value, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid variable %q, expecting name=value", value)
+		}
+		v, err := parseVarValue(value[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of variable %s: %s", value[:i], err)
+		}
+		flattenVars(value[:i], v, instance.Vars)
+	}
+	return instance, nil
+}
+
+// parseVarValue decodes the YAML value of a variable set on the command line. Numbers that don't
+// format back to the same text, such as the version 5.10, are kept as strings.
+func parseVarValue(text string) (interface{}, error) {
+	var v interface{}
+	if err := yaml.Unmarshal([]byte(text), &v); err != nil {
+		return nil, err
+	}
+	if f, ok := v.(float64); ok && strconv.FormatFloat(f, 'g', -1, 64) != strings.TrimSpace(text) {
+		return text, nil
+	}
+	return v, nil
+}
+
+// loadVars reads variables of a JSON or YAML file into vars
+func loadVars(filename string, vars VarMap) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("invalid variables file %s: %s", filename, err)
+	}
+	flattenVars("", values, vars)
+	return nil
+}
+
+// flattenVars stores variables of decoded JSON or YAML in vars, naming values
+// of nested maps by their dotted path and converting numbers to integers
+func flattenVars(prefix string, value interface{}, vars VarMap) {
+	name := func(key interface{}) string {
+		if prefix == "" {
+			return fmt.Sprint(key)
+		}
+		return prefix + "." + fmt.Sprint(key)
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			flattenVars(name(k), v, vars)
+		}
+	case map[interface{}]interface{}:
+		for k, v := range value {
+			flattenVars(name(k), v, vars)
+		}
+	default:
+		if prefix != "" {
+			vars[prefix] = varValue(value)
+		}
+	}
+}
 
+func varValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case int:
+		return int64(value)
+	case uint64:
+		return value
+	case float64:
+		if value == float64(int64(value)) {
+			return int64(value)
+		}
+		return value
+	case []interface{}:
+		array := make([]interface{}, len(value))
+		for i, v := range value {
+			array[i] = varValue(v)
+		}
+		return array
+	default:
+		return value
+	}
 }
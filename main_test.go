@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func newCommandFixtures(t *testing.T) (string, func()) {
+	t.Helper()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "expressionist")
+	assert.NoError(err)
+
+	files := map[string]string{
+		"vars.yaml": `
+file:
+  owner: root
+  permissions: 0644
+ports: [22, 80]
+`,
+		"vars.json": `{"kernel": {"version": "5.4.0"}, "ratio": 0.5}`,
+		"invalid.yaml": `
+rules:
+  - expression: a
+  - expression: a +
+`,
+		"errors.yaml": `
+rules:
+  - name: passing
+    expression: 1 == 1
+  - name: failing
+    expression: 1 == 2
+  - name: syntax error
+    expression: 1 ==
+  - name: evaluation error
+    expression: missing == 1
+`,
+		"rules.yaml": `
+vars:
+  file.owner: root
+rules:
+  - name: owned by root
+    expression: file.owner == "root"
+  - name: owned by alice
+    expression: file.owner == user
+    vars:
+      user: alice
+    expect: false
+  - name: ssh port
+    expression: port in [22, 2222]
+`,
+	}
+	for name, content := range files {
+		assert.NoError(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	dir, cleanup := newCommandFixtures(t)
+	defer cleanup()
+
+	tests := []struct {
+		name         string
+		args         []string
+		expectStatus int
+		expectStdout string
+		expectStderr string
+	}{
+		{
+			name:         "no command",
+			expectStatus: exitError,
+			expectStderr: usage,
+		},
+		{
+			name:         "unknown command",
+			args:         []string{"run"},
+			expectStatus: exitError,
+			expectStderr: "expressionist: unknown command \"run\"\n",
+		},
+		{
+			name:         "eval true",
+			args:         []string{"eval", `"abc" + "def"`},
+			expectStatus: exitPassed,
+			expectStdout: "abcdef\n",
+		},
+		{
+			name:         "eval false",
+			args:         []string{"eval", `1 > 2`},
+			expectStatus: exitFailed,
+			expectStdout: "false\n",
+		},
+		{
+			name:         "eval with yaml vars",
+			args:         []string{"eval", "--vars", filepath.Join(dir, "vars.yaml"), `file.owner == "root" && file.permissions == 0644 && 80 in ports`},
+			expectStatus: exitPassed,
+			expectStdout: "true\n",
+		},
+		{
+			name:         "eval with json vars",
+			args:         []string{"eval", `semver(kernel.version) >= "5.4"`, "--vars", filepath.Join(dir, "vars.json")},
+			expectStatus: exitPassed,
+			expectStdout: "true\n",
+		},
+		{
+			name:         "eval with var flags",
+			args:         []string{"eval", "--var", "file.owner=root", "--var", "file.size=1024", `file.owner == "root" && file.size < 1KiB`},
+			expectStatus: exitFailed,
+			expectStdout: "false\n",
+		},
+		{
+			name:         "eval with version var",
+			args:         []string{"eval", "--var", "kernel.version=5.10", `semver(kernel.version) > "5.9" && kernel.version == "5.10"`},
+			expectStatus: exitPassed,
+			expectStdout: "true\n",
+		},
+		{
+			name:         "eval with trailing zero var",
+			args:         []string{"eval", "--var", "count=5.0", "--var", "size=5", `count == "5.0" && size == 5`},
+			expectStatus: exitPassed,
+			expectStdout: "true\n",
+		},
+		{
+			name:         "eval with overridden var",
+			args:         []string{"eval", "--vars", filepath.Join(dir, "vars.yaml"), "--var", "file.owner=alice", `file.owner`},
+			expectStatus: exitPassed,
+			expectStdout: "alice\n",
+		},
+		{
+			name:         "eval invalid var",
+			args:         []string{"eval", "--var", "owner", `owner`},
+			expectStatus: exitError,
+			expectStderr: "expressionist: invalid variable \"owner\", expecting name=value\n",
+		},
+		{
+			name:         "eval syntax error",
+			args:         []string{"eval", `1 >`},
+			expectStatus: exitError,
+			expectStderr: "expressionist: 1:4: unexpected token \"<EOF>\" (expected \"[\" | \"!\" | \"-\" | \"^\" | <size> | \"-\" | \"+\" | <duration> | \"-\" | \"+\" | <hex> | <octal> | <decimal> | \"-\" | \"+\" | <string> | <rawstring> | <ident> | <ident> | \"(\")\n",
+		},
+		{
+			name:         "eval evaluation error",
+			args:         []string{"eval", `owner == "root"`},
+			expectStatus: exitError,
+			expectStderr: "expressionist: 1:1: unknown variable \"owner\"\n",
+		},
+		{
+			name:         "eval expression starting with a dash",
+			args:         []string{"eval", "--var", "x=1", "--", "-1KiB < x"},
+			expectStatus: exitPassed,
+			expectStdout: "true\n",
+		},
+		{
+			name:         "eval arguments starting with a dash",
+			args:         []string{"eval", "--", "-1", "--var"},
+			expectStatus: exitError,
+			expectStderr: "expressionist: eval expects exactly one argument\n",
+		},
+		{
+			name:         "eval missing expression",
+			args:         []string{"eval"},
+			expectStatus: exitError,
+			expectStderr: "expressionist: eval expects exactly one argument\n",
+		},
+		{
+			name:         "parse",
+			args:         []string{"parse", `x`},
+			expectStatus: exitPassed,
+			expectStdout: `&main.Expression{
+  Pos: Position{Filename: "", Offset: 0, Line: 1, Column: 1},
+  Comparison: &main.Comparison{
+    Pos: Position{Filename: "", Offset: 0, Line: 1, Column: 1},
+    Term: &main.Term{
+      Pos: Position{Filename: "", Offset: 0, Line: 1, Column: 1},
+      Unary: &main.Unary{
+        Pos: Position{Filename: "", Offset: 0, Line: 1, Column: 1},
+        Value: &main.Value{
+          Pos: Position{Filename: "", Offset: 0, Line: 1, Column: 1},
+          Variable: &"x",
+        },
+      },
+    },
+  },
+}
+`,
+		},
+		{
+			name:         "check",
+			args:         []string{"check", filepath.Join(dir, "rules.yaml"), "--var", "port=22"},
+			expectStatus: exitPassed,
+			expectStdout: "PASS owned by root\nPASS owned by alice\nPASS ssh port\n",
+		},
+		{
+			name:         "check failure",
+			args:         []string{"check", "--var", "port=8080", "--var", "file.owner=alice", filepath.Join(dir, "rules.yaml")},
+			expectStatus: exitFailed,
+			expectStdout: "FAIL owned by root: expected true, got false\nFAIL owned by alice: expected false, got true\nFAIL ssh port: expected true, got false\n",
+		},
+		{
+			name:         "check errors",
+			args:         []string{"check", filepath.Join(dir, "errors.yaml")},
+			expectStatus: exitError,
+			expectStdout: "PASS passing\nFAIL failing: expected true, got false\nERROR syntax error: 1:5: unexpected token \"<EOF>\" (expected \"[\" | \"!\" | \"-\" | \"^\" | <size> | \"-\" | \"+\" | <duration> | \"-\" | \"+\" | <hex> | <octal> | <decimal> | \"-\" | \"+\" | <string> | <rawstring> | <ident> | <ident> | \"(\")\nERROR evaluation error: 1:1: unknown variable \"missing\"\n",
+		},
+		{
+			name:         "fmt",
+			args:         []string{"fmt", filepath.Join(dir, "rules.yaml")},
+			expectStatus: exitPassed,
+			expectStdout: `
+vars:
+  file.owner: root
+rules:
+  - name: owned by root
+    expression: file.owner == "root"
+  - name: owned by alice
+    expression: file.owner == user
+    vars:
+      user: alice
+    expect: false
+  - name: ssh port
+    expression: port in [22, 2222]
+`,
+		},
+		{
+			name:         "fmt invalid expression",
+			args:         []string{"fmt", filepath.Join(dir, "invalid.yaml")},
+			expectStatus: exitError,
+			expectStderr: "expressionist: invalid rules file " + filepath.Join(dir, "invalid.yaml") + ": rule #2: 1:4: unexpected token \"<EOF>\" (expected \"!\" | \"-\" | \"^\" | <size> | \"-\" | \"+\" | <duration> | \"-\" | \"+\" | <hex> | <octal> | <decimal> | \"-\" | \"+\" | <string> | <rawstring> | <ident> | <ident> | \"(\")\n",
+		},
+		{
+			name:         "check missing file",
+			args:         []string{"check", filepath.Join(dir, "missing.yaml")},
+			expectStatus: exitError,
+			expectStderr: "expressionist: open " + filepath.Join(dir, "missing.yaml") + ": no such file or directory\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			var stdout, stderr bytes.Buffer
+			status := run(test.args, strings.NewReader(""), &stdout, &stderr)
+			assert.Equal(test.expectStderr, stderr.String())
+			assert.Equal(test.expectStdout, stdout.String())
+			assert.Equal(test.expectStatus, status)
+		})
+	}
+}
+
+func TestCommandFmtWrite(t *testing.T) {
+	assert := assert.New(t)
+	dir, cleanup := newCommandFixtures(t)
+	defer cleanup()
+
+	filename := filepath.Join(dir, "unformatted.json")
+	assert.NoError(ioutil.WriteFile(filename, []byte(`{"rules": [{"name": "a", "expression": "(x)==1&&y"}]}`), 0600))
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"fmt", "-w", filename}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(exitPassed, status)
+	assert.Empty(stdout.String())
+	assert.Empty(stderr.String())
+
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(err)
+	assert.Equal(`{"rules": [{"name": "a", "expression": "x == 1 && y"}]}`, string(data))
+
+	info, err := os.Stat(filename)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), info.Mode())
+}
+
+func TestFormatRules(t *testing.T) {
+	tests := []struct {
+		name         string
+		rules        string
+		expectResult string
+		expectError  string
+	}{
+		{
+			name: "comments and layout",
+			rules: `# ownership rules
+rules:
+  # root only
+  - name: owned by root # inline
+    expression: file.owner=="root" # canonical form
+    expect: true
+
+  - {name: port, expression: "port in [22,2222]"}
+`,
+			expectResult: `# ownership rules
+rules:
+  # root only
+  - name: owned by root # inline
+    expression: file.owner == "root" # canonical form
+    expect: true
+
+  - {name: port, expression: "port in [22, 2222]"}
+`,
+		},
+		{
+			name: "quoting styles",
+			rules: `rules:
+  - expression: "a<b"
+  - 'expression': '(a)=="it''s"'
+  - expression: |-
+      a&&b
+  - expression: 'a == "x"'
+  - expression: a==1
+  - expression: >-
+      b ==
+      2
+`,
+			expectResult: `rules:
+  - expression: "a < b"
+  - 'expression': 'a == "it''s"'
+  - expression: |-
+      a && b
+  - expression: 'a == "x"'
+  - expression: a == 1
+  - expression: >-
+      b == 2
+`,
+		},
+		{
+			name:         "plain scalars",
+			rules:        "rules:\n  - expression: a=='#'\n  - expression:   a==\"x\"   # x\n",
+			expectResult: "rules:\n  - expression: a == \"#\"\n  - expression:   a == \"x\"   # x\n",
+		},
+		{
+			name:         "json",
+			rules:        "{\n  \"rules\": [\n    {\"expression\":\"a<1\"},\n    {\"expression\": \"a\\u0026\\u0026b\"}\n  ]\n}\n",
+			expectResult: "{\n  \"rules\": [\n    {\"expression\":\"a < 1\"},\n    {\"expression\": \"a && b\"}\n  ]\n}\n",
+		},
+		{
+			name:        "invalid rules",
+			rules:       "rules: a",
+			expectError: "rules must be a list",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			result, err := formatRules([]byte(test.rules))
+			if test.expectError != "" {
+				assert.EqualError(err, test.expectError)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectResult, string(result))
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// NetworkFunctions returns functions producing network values:
+//   - ip(s) parses an IPv4 or IPv6 address
+//   - cidr(s) parses a network in CIDR notation
+//
+// IP addresses compare with other addresses or strings using scalar comparison operators
+// and match networks or strings in CIDR notation with in and not in operators,
+// e.g. addr in cidr("10.0.0.0/8") or ip(addr) in ["192.168.0.0/16", "10.0.0.0/8"].
+func NetworkFunctions() FunctionMap {
+	return FunctionMap{
+		"ip": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			s, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf(`invalid IP address "%s"`, s)
+			}
+			return ip, nil
+		},
+		"cidr": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			s, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			_, network, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid CIDR "%s"`, s)
+			}
+			return network, nil
+		},
+	}
+}
+
+// ipValue converts an IP address or its string representation to net.IP
+func ipValue(value interface{}) (net.IP, bool) {
+	switch value := value.(type) {
+	case net.IP:
+		return value, true
+	case string:
+		ip := net.ParseIP(value)
+		return ip, ip != nil
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestEvalNetwork(t *testing.T) {
+	functions := NetworkFunctions()
+	instanceTests{
+		{
+			name:         "ip",
+			expression:   `ip("10.0.0.1")`,
+			functions:    functions,
+			expectResult: net.ParseIP("10.0.0.1"),
+		},
+		{
+			name:         "ip equality",
+			expression:   `ip("::ffff:10.0.0.1") == "10.0.0.1"`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "ip ordering",
+			expression:   `ip("10.0.0.2") > ip("10.0.0.10")`,
+			functions:    functions,
+			expectResult: false,
+		},
+		{
+			name:         "in cidr",
+			expression:   `addr in cidr("10.0.0.0/8")`,
+			vars:         VarMap{"addr": "10.1.2.3"},
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "not in cidr",
+			expression:   `ip(addr) not in cidr("10.0.0.0/8")`,
+			vars:         VarMap{"addr": "192.168.1.1"},
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "in cidr array",
+			expression:   `ip(addr) in ["192.168.0.0/16", "10.0.0.0/8"]`,
+			vars:         VarMap{"addr": "192.168.1.1"},
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "not in cidr array",
+			expression:   `ip(addr) in ["192.168.0.0/16", "10.0.0.0/8"]`,
+			vars:         VarMap{"addr": "172.16.0.1"},
+			functions:    functions,
+			expectResult: false,
+		},
+		{
+			name:         "in mixed array",
+			expression:   `ip("127.0.0.1") in [cidr("10.0.0.0/8"), "::1", "127.0.0.1"]`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "in ipv6 cidr",
+			expression:   `ip("fd00::1") in cidr("fd00::/8")`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "ipv4 not in ipv6 cidr",
+			expression:   `ip("10.0.0.1") in cidr("fd00::/8")`,
+			functions:    functions,
+			expectResult: false,
+		},
+		{
+			name:         "in variable networks",
+			expression:   `ip(addr) in trusted`,
+			vars:         VarMap{"addr": "10.0.0.1", "trusted": []interface{}{"10.0.0.0/24"}},
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "string in cidr array",
+			expression:   `"10.0.0.1" in ["10.0.0.0/8"]`,
+			expectResult: true,
+		},
+		{
+			name:         "string not in cidr array",
+			expression:   `addr in ["192.168.0.0/16", "10.0.0.0/8", "localhost"]`,
+			vars:         VarMap{"addr": "172.16.0.1"},
+			expectResult: false,
+		},
+		{
+			name:         "cidr string in array",
+			expression:   `"10.0.0.0/8" in ["10.0.0.0/8"] && "localhost" in ["127.0.0.0/8", "localhost"]`,
+			expectResult: true,
+		},
+		{
+			name:         "string in ip array",
+			expression:   `"::ffff:10.0.0.1" in [ip("10.0.0.1")] && "10.0.0.2" not in [ip("10.0.0.1")]`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "string compared to ip",
+			expression:   `"10.0.0.1" == ip("::ffff:10.0.0.1") && "10.0.0.10" > ip("10.0.0.2")`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:        "non address compared to ip",
+			expression:  `"localhost" == ip("127.0.0.1")`,
+			functions:   functions,
+			expectError: newLexerError(0, "lhs of == must be an IP address"),
+		},
+		{
+			name:         "non address in cidr",
+			expression:   `"localhost" in cidr("127.0.0.0/8")`,
+			functions:    functions,
+			expectResult: false,
+		},
+		{
+			name:        "invalid ip comparison",
+			expression:  `ip("10.0.0.1") == 1`,
+			functions:   functions,
+			expectError: newLexerError(0, "rhs of == must be an IP address"),
+		},
+		{
+			name:        "invalid ip",
+			expression:  `ip("10.0.0.256")`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "ip()" failed: invalid IP address "10.0.0.256"`),
+		},
+		{
+			name:        "invalid cidr",
+			expression:  `"10.0.0.1" in cidr("10.0.0.0/33")`,
+			functions:   functions,
+			expectError: newLexerError(14, `call to "cidr()" failed: invalid CIDR "10.0.0.0/33"`),
+		},
+		{
+			name:        "call not returning array",
+			expression:  `"10.0.0.1" in ip("10.0.0.1")`,
+			functions:   functions,
+			expectError: newLexerError(0, "rhs of in array operation must be an array"),
+		},
+	}.Run(t)
+}
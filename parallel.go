@@ -0,0 +1,138 @@
+package main
+
+import "sync"
+
+// iteration captures the outcome of evaluating a single instance of an iterator
+type iteration struct {
+	index    int
+	instance *Instance
+	value    interface{}
+	err      error
+	// abort is set for errors aborting evaluation regardless of the error policy
+	abort bool
+}
+
+// visitParallel evaluates expression for instances of an iterator using a pool of workers.
+//
+// Instances are pulled from the iterator by a single goroutine since iterators are not expected
+// to be safe for concurrent use, while evaluation results are reordered and passed to visit
+// in iteration order. This keeps results and reported errors identical to sequential evaluation,
+// while stopping early cancels any outstanding work. At most twice as many instances as workers
+// are pulled ahead of the next instance in order, bounding the results waiting to be reordered.
+func visitParallel(it Iterator, global *Instance, expression *Expression, options IterableOptions, visit visitFunc) (*Instance, []*InstanceError, error) {
+	var (
+		wg      sync.WaitGroup
+		jobs    = make(chan iteration)
+		results = make(chan iteration)
+		done    = make(chan struct{})
+		// slots holds an element for each instance pulled but not yet visited
+		slots = make(chan struct{}, 2*options.Workers)
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		guard := iteratorErrors{max: options.MaxIteratorErrors}
+		for index := 0; !it.Done(); index++ {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			instance, err := it.Next()
+			job := iteration{index: index, instance: instance, err: err}
+			if err := guard.check(err); err != nil {
+				job.err, job.abort = err, true
+			}
+			select {
+			case jobs <- job:
+			case <-done:
+				return
+			}
+			if job.abort || err != nil && options.ErrorPolicy == AbortOnError {
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if job.err == nil {
+					job.value, job.err = evaluateInScope(expression, job.instance, global)
+				}
+				select {
+				case results <- job:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var (
+		last    *Instance
+		errs    []*InstanceError
+		pending = make(map[int]iteration)
+		next    int
+		stopped bool
+		err     error
+	)
+
+	stop := func() {
+		stopped = true
+		close(done)
+	}
+
+	// Results are drained even after stopping so that no goroutine outlives the evaluation
+	for job := range results {
+		if stopped {
+			continue
+		}
+		pending[job.index] = job
+		for !stopped {
+			job, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-slots
+
+			if job.abort {
+				err = job.err
+				stop()
+				continue
+			}
+
+			var proceed bool
+			if job.err == nil {
+				proceed, job.err = visit(job.instance, job.value)
+			}
+			if job.err != nil {
+				if err = options.handleError(&errs, job.index, job.instance, job.err); err != nil {
+					stop()
+				}
+				continue
+			}
+
+			last = job.instance
+			if !proceed {
+				stop()
+			}
+		}
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return last, errs, nil
+}
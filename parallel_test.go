@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func newFileInstances(count int) []*Instance {
+	var instances []*Instance
+	for i := 0; i < count; i++ {
+		owner := "root"
+		if i%7 == 3 {
+			owner = "alice"
+		}
+		instances = append(instances, &Instance{
+			Vars: VarMap{
+				"file.index": i,
+				"file.owner": owner,
+			},
+		})
+	}
+	return instances
+}
+
+type countingIterator struct {
+	iteratorMock
+	failAt int
+	next   int
+}
+
+func (i *countingIterator) Next() (*Instance, error) {
+	i.next++
+	if i.failAt != 0 && i.index == i.failAt {
+		i.index++
+		return nil, errors.New("iterator failed")
+	}
+	return i.iteratorMock.Next()
+}
+
+func TestEvalIterableParallel(t *testing.T) {
+	instances := newFileInstances(500)
+
+	expressions := []string{
+		`all(file.owner == "root")`,
+		`all(file.index >= 0)`,
+		`any(file.owner == "alice")`,
+		`any(file.owner == "bob")`,
+		`none(file.owner == "alice")`,
+		`none(file.owner == "bob")`,
+		`len(file.owner == "alice") == 71`,
+		`file.owner == "root"`,
+		`file.index < 1000`,
+		`unique(file.owner)`,
+		`unique(file.index)`,
+		`distinct(file.owner) == 2`,
+		`groupBy(file.owner) > 10`,
+	}
+
+	for _, expression := range expressions {
+		for _, workers := range []int{2, 4, 16} {
+			t.Run(fmt.Sprintf("%s with %d workers", expression, workers), func(t *testing.T) {
+				assert := assert.New(t)
+				expr, err := ParseIterable(expression)
+				assert.NoError(err)
+
+				expected, err := expr.Evaluate(&iteratorMock{instances: instances}, &Instance{})
+				assert.NoError(err)
+
+				actual, err := expr.EvaluateWithOptions(&iteratorMock{instances: instances}, &Instance{}, IterableOptions{
+					Workers: workers,
+				})
+				assert.NoError(err)
+				assert.Equal(expected.Passed, actual.Passed)
+				assert.True(expected.Instance == actual.Instance, "expected the same instance in result")
+			})
+		}
+	}
+}
+
+func TestEvalIterableParallelEarlyExit(t *testing.T) {
+	assert := assert.New(t)
+	instances := newFileInstances(1000)
+
+	expr, err := ParseIterable(`all(file.index > 3)`)
+	assert.NoError(err)
+
+	it := &countingIterator{
+		iteratorMock: iteratorMock{instances: instances},
+	}
+	result, err := expr.EvaluateWithOptions(it, &Instance{}, IterableOptions{Workers: 4})
+	assert.NoError(err)
+	assert.False(result.Passed)
+	assert.True(instances[0] == result.Instance, "expected the first instance in result")
+	assert.Less(it.next, len(instances))
+}
+
+// pullCountingIterator counts instances pulled from it for use across goroutines
+type pullCountingIterator struct {
+	iteratorMock
+	pulled int32
+}
+
+func (i *pullCountingIterator) Next() (*Instance, error) {
+	atomic.AddInt32(&i.pulled, 1)
+	return i.iteratorMock.Next()
+}
+
+func TestEvalIterableParallelBoundedAhead(t *testing.T) {
+	assert := assert.New(t)
+	const workers = 4
+	release := make(chan struct{})
+	global := &Instance{
+		Functions: FunctionMap{
+			"wait": func(instance *Instance, args ...interface{}) (interface{}, error) {
+				if index, _, _ := instance.Var("file.index"); index == 0 {
+					<-release
+				}
+				return true, nil
+			},
+		},
+	}
+	expr, err := ParseIterable(`all(wait())`)
+	assert.NoError(err)
+
+	it := &pullCountingIterator{iteratorMock: iteratorMock{instances: newFileInstances(1000)}}
+	var pulled int32
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pulled = atomic.LoadInt32(&it.pulled)
+		close(release)
+	}()
+	result, err := expr.EvaluateWithOptions(it, global, IterableOptions{Workers: workers})
+	assert.NoError(err)
+	assert.True(result.Passed)
+	assert.LessOrEqual(pulled, int32(2*workers))
+	assert.Equal(int32(1000), atomic.LoadInt32(&it.pulled))
+}
+
+func TestEvalIterableParallelErrors(t *testing.T) {
+	instances := newFileInstances(200)
+	instances[120] = &Instance{}
+
+	tests := []struct {
+		name        string
+		expression  string
+		failAt      int
+		expectError error
+	}{
+		{
+			name:        "iterator error",
+			expression:  `all(file.index >= 0)`,
+			failAt:      50,
+			expectError: errors.New("iterator failed"),
+		},
+		{
+			name:        "evaluation error",
+			expression:  `all(file.index >= 0)`,
+			expectError: newLexerError(4, `unknown variable "file.index"`),
+		},
+		{
+			name:       "evaluation error after early exit",
+			expression: `any(file.index == 10)`,
+		},
+		{
+			name:        "first error in iteration order",
+			expression:  `all(file.index >= 0)`,
+			failAt:      150,
+			expectError: newLexerError(4, `unknown variable "file.index"`),
+		},
+	}
+
+	for _, test := range tests {
+		for _, workers := range []int{1, 2, 8} {
+			t.Run(fmt.Sprintf("%s with %d workers", test.name, workers), func(t *testing.T) {
+				assert := assert.New(t)
+				expr, err := ParseIterable(test.expression)
+				assert.NoError(err)
+
+				it := &countingIterator{
+					iteratorMock: iteratorMock{instances: instances},
+					failAt:       test.failAt,
+				}
+				result, err := expr.EvaluateWithOptions(it, &Instance{}, IterableOptions{Workers: workers})
+				if test.expectError != nil {
+					assert.Equal(test.expectError, err)
+				} else {
+					assert.NoError(err)
+					assert.True(result.Passed)
+				}
+			})
+		}
+	}
+}
+
+// stuckIterator fails to produce an instance without advancing past it
+type stuckIterator struct {
+	iteratorMock
+	stuckAt int
+}
+
+func (i *stuckIterator) Next() (*Instance, error) {
+	if i.index == i.stuckAt {
+		return nil, errors.New("iterator failed")
+	}
+	return i.iteratorMock.Next()
+}
+
+func TestEvalIterableMaxIteratorErrors(t *testing.T) {
+	for _, policy := range []ErrorPolicy{AbortOnError, SkipErrors, CollectErrors} {
+		for _, workers := range []int{1, 8} {
+			t.Run(fmt.Sprintf("policy %d with %d workers", policy, workers), func(t *testing.T) {
+				assert := assert.New(t)
+				expr, err := ParseIterable(`all(file.index >= 0)`)
+				assert.NoError(err)
+
+				it := &stuckIterator{
+					iteratorMock: iteratorMock{instances: newFileInstances(20)},
+					stuckAt:      10,
+				}
+				_, err = expr.EvaluateWithOptions(it, &Instance{}, IterableOptions{
+					Workers:           workers,
+					ErrorPolicy:       policy,
+					MaxIteratorErrors: 100,
+				})
+				if policy == AbortOnError {
+					assert.EqualError(err, "iterator failed")
+				} else {
+					assert.EqualError(err, "iterator failed 100 times in a row: iterator failed")
+				}
+			})
+		}
+	}
+}
+
+// advancingIterator fails to produce all instances while advancing past them
+type advancingIterator struct {
+	iteratorMock
+}
+
+func (i *advancingIterator) Next() (*Instance, error) {
+	i.index++
+	return nil, os.ErrPermission
+}
+
+func TestEvalIterableAdvancingIteratorErrors(t *testing.T) {
+	for _, workers := range []int{1, 8} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			assert := assert.New(t)
+			expr, err := ParseIterable(`all(file.index >= 0)`)
+			assert.NoError(err)
+
+			it := &advancingIterator{iteratorMock{instances: newFileInstances(300)}}
+			result, err := expr.EvaluateWithOptions(it, &Instance{}, IterableOptions{Workers: workers, ErrorPolicy: CollectErrors})
+			assert.NoError(err)
+			assert.Len(result.Errors, 300)
+		})
+	}
+}
@@ -10,13 +10,20 @@ var (
 	expressionLexer = lexer.Must(ebnf.New(`
 		Hex = ("0" "x") hexdigit { hexdigit } .
 		Ident = (alpha | "_") { "_" | "." | alpha | digit } .
-		String = "\"" { "\u0000"…"\uffff"-"\""-"\\" | "\\" any } "\"" .
-		UnixSystemPath = "/" alpha { alpha | digit | "-" | "." | "_" | "/" } ["*" [ "." { alpha | digit } ] ].
+		String = "\"" { "\u0000"…"\uffff"-"\""-"\\" | "\\" any } "\""
+			| "'" { "\u0000"…"\uffff"-"'"-"\\" | "\\" any } "'" .
+		RawString = ` + "\"`\"" + ` { "\u0000"…"\uffff"-` + "\"`\"" + ` } ` + "\"`\"" + ` .
+		UnixSystemPath = "/" { pathchar | "\\" any } .
+		Size = digit { digit } [ "." digit { digit } ] sizeunit .
+		Duration = digit { digit } durationunit { digit { digit } durationunit } .
 		Octal = "0" octaldigit { octaldigit } .
-		Decimal = [ "-" | "+" ] digit { digit } .
+		Decimal = digit { digit } .
 		Punct = "!"…"/" | ":"…"@" | "["…` + "\"`\"" + ` | "{"…"~" .
 		Whitespace = ( " " | "\t" ) { " " | "\t" } .
 		alpha = "a"…"z" | "A"…"Z" .
+		pathchar = "!"…"\uffff"-"\""-"'"-` + "\"`\"" + `-"("-")"-"\\" .
+		durationunit = "n" "s" | "u" "s" | "µ" "s" | "m" [ "s" ] | "s" | "h" | "d" | "w" .
+		sizeunit = [ ( "k" | "K" | "M" | "G" | "T" | "P" ) [ "i" ] ] "B" .
 		octaldigit = "0"…"7" .
 		hexdigit = "A"…"F" | "a"…"f" | digit .
 		digit = "0"…"9" .
@@ -26,6 +33,9 @@ var (
 	expressionOptions = []participle.Option{
 		participle.Lexer(expressionLexer),
 		participle.Unquote("String"),
+		participle.Map(unquoteRawString, "RawString"),
+		participle.Map(checkDuration, "Duration"),
+		participle.Map(checkSize, "Size"),
 		participle.UseLookahead(2),
 		participle.Elide("Whitespace"),
 	}
@@ -37,6 +47,12 @@ var (
 	pathParser = participle.MustBuild(&PathExpression{}, expressionOptions...)
 )
 
+// unquoteRawString strips backticks of a raw string leaving its contents uninterpreted
+func unquoteRawString(t lexer.Token) (lexer.Token, error) {
+	t.Value = t.Value[1 : len(t.Value)-1]
+	return t, nil
+}
+
 // ParseExpression parses Expression from a string
 func ParseExpression(s string) (*Expression, error) {
 	expr := &Expression{}
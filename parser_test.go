@@ -29,3 +29,229 @@ func TestParsePathError(t *testing.T) {
 	assert.Nil(expr)
 	assert.EqualError(err, `1:1: unexpected token "="`)
 }
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		path       string
+	}{
+		{
+			name:       "digit leading segment",
+			expression: `/etc/2fa.conf`,
+			path:       `/etc/2fa.conf`,
+		},
+		{
+			name:       "numeric segment",
+			expression: `/proc/1/status`,
+			path:       `/proc/1/status`,
+		},
+		{
+			name:       "root",
+			expression: `/`,
+			path:       `/`,
+		},
+		{
+			name:       "wildcard in last segment",
+			expression: `/etc/ssh/sshd_config.d/*.conf`,
+			path:       `/etc/ssh/sshd_config.d/*.conf`,
+		},
+		{
+			name:       "wildcard in any segment",
+			expression: `/home/*/.ssh/authorized_keys`,
+			path:       `/home/*/.ssh/authorized_keys`,
+		},
+		{
+			name:       "single character wildcard",
+			expression: `/dev/tty?`,
+			path:       `/dev/tty?`,
+		},
+		{
+			name:       "recursive wildcard",
+			expression: `/var/**/*.sock`,
+			path:       `/var/**/*.sock`,
+		},
+		{
+			name:       "character class",
+			expression: `/etc/rc[0-6].d/S*`,
+			path:       `/etc/rc[0-6].d/S*`,
+		},
+		{
+			name:       "negated character class",
+			expression: `/etc/cron.[!d]*`,
+			path:       `/etc/cron.[!d]*`,
+		},
+		{
+			name:       "alternation",
+			expression: `/etc/{passwd,group}`,
+			path:       `/etc/{passwd,group}`,
+		},
+		{
+			name:       "special characters",
+			expression: `/home/~alice/c++/user@host:8080/a=b%20`,
+			path:       `/home/~alice/c++/user@host:8080/a=b%20`,
+		},
+		{
+			name:       "escaped space",
+			expression: `/home/alice/My\ Documents/notes.txt`,
+			path:       `/home/alice/My\ Documents/notes.txt`,
+		},
+		{
+			name:       "escaped wildcard",
+			expression: `/tmp/\*`,
+			path:       `/tmp/\*`,
+		},
+		{
+			name:       "surrounding whitespace",
+			expression: `  /etc/passwd  `,
+			path:       `/etc/passwd`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			expr, err := ParsePath(test.expression)
+			assert.NoError(err)
+			assert.NotNil(expr.Path)
+			assert.Equal(test.path, *expr.Path)
+		})
+	}
+}
+
+func TestParseQuotedPath(t *testing.T) {
+	assert := assert.New(t)
+	expr, err := ParsePath(`"/home/alice/My Documents/(draft), final.txt"`)
+	assert.NoError(err)
+	assert.Nil(expr.Path)
+
+	value, err := expr.Evaluate(&Instance{})
+	assert.NoError(err)
+	assert.Equal("/home/alice/My Documents/(draft), final.txt", value)
+}
+
+func TestParsePathTrailingInput(t *testing.T) {
+	assert := assert.New(t)
+	expr, err := ParsePath(`/etc/passwd /etc/group`)
+
+	assert.Nil(expr)
+	assert.EqualError(err, `1:13: unexpected token "/etc/group"`)
+}
+
+func TestParseString(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		expected   string
+	}{
+		{
+			name:       "double-quoted",
+			expression: `"abc"`,
+			expected:   "abc",
+		},
+		{
+			name:       "single-quoted",
+			expression: `'abc'`,
+			expected:   "abc",
+		},
+		{
+			name:       "single-quoted with double quotes",
+			expression: `'say "hi"'`,
+			expected:   `say "hi"`,
+		},
+		{
+			name:       "single-quoted with escaped quote",
+			expression: `'it\'s'`,
+			expected:   "it's",
+		},
+		{
+			name:       "double-quoted with escaped quote",
+			expression: `"say \"hi\""`,
+			expected:   `say "hi"`,
+		},
+		{
+			name:       "escape sequences",
+			expression: `"a\tb\nc\\d"`,
+			expected:   "a\tb\nc\\d",
+		},
+		{
+			name:       "unicode escapes",
+			expression: `"café \U0001f600 \x41"`,
+			expected:   "café 😀 A",
+		},
+		{
+			name:       "raw",
+			expression: "`^/etc/.*\\.conf$`",
+			expected:   `^/etc/.*\.conf$`,
+		},
+		{
+			name:       "raw with quotes and newline",
+			expression: "`'a'\n\"b\"`",
+			expected:   "'a'\n\"b\"",
+		},
+		{
+			name:       "empty raw",
+			expression: "``",
+			expected:   "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			expr, err := ParseExpression(test.expression)
+			assert.NoError(err)
+
+			value, err := expr.Evaluate(&Instance{})
+			assert.NoError(err)
+			assert.Equal(test.expected, value)
+		})
+	}
+}
+
+func TestParseStringInvalidEscape(t *testing.T) {
+	assert := assert.New(t)
+	expr, err := ParseExpression(`'a\qb'`)
+
+	assert.Nil(expr)
+	assert.EqualError(err, `1:1: invalid quoted string "'a\\qb'": invalid syntax`)
+}
+
+func TestParseRawStringRegexp(t *testing.T) {
+	assert := assert.New(t)
+	expr, err := ParseExpression("\"/etc/ssh/sshd.conf\" =~ `^/etc/.*\\.conf$`")
+	assert.NoError(err)
+
+	value, err := expr.Evaluate(&Instance{})
+	assert.NoError(err)
+	assert.Equal(true, value)
+}
+
+func TestParseLetError(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		expectError string
+	}{
+		{
+			name:        "missing in",
+			expression:  `let x = 1`,
+			expectError: `1:10: unexpected token "<EOF>" (expected "in")`,
+		},
+		{
+			name:        "unparenthesised comparison",
+			expression:  `let x = a == 1 in x`,
+			expectError: `1:11: unexpected token "=" (expected "in")`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			expr, err := ParseExpression(test.expression)
+
+			assert.Nil(expr)
+			assert.EqualError(err, test.expectError)
+		})
+	}
+}
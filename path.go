@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+
+	"github.com/alecthomas/participle/lexer"
+)
+
+// Resolve evaluates a path expression for an instance and returns an iterator over instances
+// for files of a filesystem matching the resulting path or glob pattern. File instances inherit
+// variables and functions of the instance.
+func (e *PathExpression) Resolve(instance *Instance, fsys FileSystem) (Iterator, error) {
+	value, err := e.Evaluate(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern, ok := value.(string)
+	if !ok {
+		return nil, lexer.Errorf(e.Pos, "expecting a string path")
+	}
+
+	paths, err := Glob(fsys, pattern)
+	if _, ok := err.(*os.PathError); ok {
+		return nil, lexer.Errorf(e.Pos, `failed to expand path pattern "%s": %s`, pattern, err)
+	} else if err != nil {
+		return nil, lexer.Errorf(e.Pos, `invalid path pattern "%s": %s`, pattern, err)
+	}
+
+	return &fileIterator{
+		fsys:   fsys,
+		parent: instance,
+		paths:  paths,
+	}, nil
+}
+
+// NewFileInstance returns an instance with standard file vars for a file at a path:
+//   - file.path is the absolute path of the file
+//   - file.name is the base name of the file
+//   - file.type is one of "file", "directory", "symlink", "socket", "pipe" or "device"
+//   - file.size is the size of the file in bytes
+//   - file.permissions are the unix permission bits of the file
+func NewFileInstance(name string, info os.FileInfo) *Instance {
+	return &Instance{
+		Vars: VarMap{
+			"file.path":        name,
+			"file.name":        path.Base(name),
+			"file.type":        fileType(info.Mode()),
+			"file.size":        info.Size(),
+			"file.permissions": uint64(info.Mode().Perm()),
+		},
+	}
+}
+
+func fileType(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeNamedPipe != 0:
+		return "pipe"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	default:
+		return "file"
+	}
+}
+
+type fileIterator struct {
+	fsys   FileSystem
+	parent *Instance
+	paths  []string
+	index  int
+}
+
+func (i *fileIterator) Next() (*Instance, error) {
+	if i.Done() {
+		return nil, errors.New("out of bounds iteration")
+	}
+
+	name := i.paths[i.index]
+	i.index++
+
+	info, err := i.fsys.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	instance := NewFileInstance(name, info)
+	instance.Parent = i.parent
+	return instance, nil
+}
+
+func (i *fileIterator) Done() bool {
+	return i.index >= len(i.paths)
+}
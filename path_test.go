@@ -0,0 +1,198 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestResolvePath(t *testing.T) {
+	fsys := newMemFileSystem(map[string]string{
+		"/etc/passwd":                         "root:x:0:0::/root:/bin/bash\n",
+		"/var/run/docker.sock":                "",
+		"/var/run/containerd/containerd.sock": "",
+		"/etc/kubernetes/":                    "",
+		"/home/alice/My Documents/notes.txt":  "notes",
+		"/root/":                              "",
+	})
+	fsys["/root"].mode = os.ModeDir
+
+	instance := &Instance{
+		Functions: FunctionMap{
+			"process.flag": func(instance *Instance, args ...interface{}) (interface{}, error) {
+				return "/etc/passwd", nil
+			},
+		},
+		Vars: VarMap{
+			"port": 22,
+		},
+	}
+
+	tests := []struct {
+		name        string
+		expression  string
+		expectVars  []VarMap
+		expectError error
+	}{
+		{
+			name:       "path",
+			expression: `/etc/passwd`,
+			expectVars: []VarMap{
+				{
+					"file.path":        "/etc/passwd",
+					"file.name":        "passwd",
+					"file.type":        "file",
+					"file.size":        int64(28),
+					"file.permissions": uint64(0644),
+				},
+			},
+		},
+		{
+			name:       "glob",
+			expression: `/var/**/*.sock`,
+			expectVars: []VarMap{
+				{
+					"file.path":        "/var/run/containerd/containerd.sock",
+					"file.name":        "containerd.sock",
+					"file.type":        "file",
+					"file.size":        int64(0),
+					"file.permissions": uint64(0644),
+				},
+				{
+					"file.path":        "/var/run/docker.sock",
+					"file.name":        "docker.sock",
+					"file.type":        "file",
+					"file.size":        int64(0),
+					"file.permissions": uint64(0644),
+				},
+			},
+		},
+		{
+			name:       "escaped space",
+			expression: `/home/*/My\ Documents/*.txt`,
+			expectVars: []VarMap{
+				{
+					"file.path":        "/home/alice/My Documents/notes.txt",
+					"file.name":        "notes.txt",
+					"file.type":        "file",
+					"file.size":        int64(5),
+					"file.permissions": uint64(0644),
+				},
+			},
+		},
+		{
+			name:       "directory",
+			expression: `"/etc/kube*"`,
+			expectVars: []VarMap{
+				{
+					"file.path":        "/etc/kubernetes",
+					"file.name":        "kubernetes",
+					"file.type":        "directory",
+					"file.size":        int64(0),
+					"file.permissions": uint64(0755),
+				},
+			},
+		},
+		{
+			name:       "path from function",
+			expression: `process.flag("kubelet", "--config")`,
+			expectVars: []VarMap{
+				{
+					"file.path":        "/etc/passwd",
+					"file.name":        "passwd",
+					"file.type":        "file",
+					"file.size":        int64(28),
+					"file.permissions": uint64(0644),
+				},
+			},
+		},
+		{
+			name:       "no matches",
+			expression: `/etc/shadow`,
+		},
+		{
+			name:        "not a string",
+			expression:  `port`,
+			expectError: newLexerError(0, "expecting a string path"),
+		},
+		{
+			name:        "invalid pattern",
+			expression:  `"/etc/{passwd"`,
+			expectError: newLexerError(0, `invalid path pattern "/etc/{passwd": unbalanced braces in pattern`),
+		},
+		{
+			name:        "unreadable directory",
+			expression:  `/root/**`,
+			expectError: newLexerError(0, `failed to expand path pattern "/root/**": readdir /root: permission denied`),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			expr, err := ParsePath(test.expression)
+			assert.NoError(err)
+
+			it, err := expr.Resolve(instance, fsys)
+			if test.expectError != nil {
+				assert.Equal(test.expectError, err)
+				return
+			}
+			assert.NoError(err)
+
+			var vars []VarMap
+			for !it.Done() {
+				instance, err := it.Next()
+				assert.NoError(err)
+				vars = append(vars, instance.Vars)
+			}
+			assert.Equal(test.expectVars, vars)
+
+			_, err = it.Next()
+			assert.Error(err)
+		})
+	}
+}
+
+func TestResolvePathIterable(t *testing.T) {
+	assert := assert.New(t)
+	fsys := newMemFileSystem(map[string]string{
+		"/etc/ssh/sshd_config":          "",
+		"/etc/ssh/ssh_host_rsa_key.pub": "",
+		"/etc/ssh/ssh_host_rsa_key":     "",
+	})
+
+	path, err := ParsePath(`"/etc/ssh/ssh*"`)
+	assert.NoError(err)
+
+	it, err := path.Resolve(&Instance{}, fsys)
+	assert.NoError(err)
+
+	expr, err := ParseIterable(`len(file.name =~ "key$") == 1`)
+	assert.NoError(err)
+
+	result, err := expr.Evaluate(it, &Instance{})
+	assert.NoError(err)
+	assert.True(result.Passed)
+}
+
+func TestResolvePathSymlink(t *testing.T) {
+	assert := assert.New(t)
+	root, cleanup := newFileFixtures(t)
+	defer cleanup()
+
+	path, err := ParsePath(`"/etc/passwd*"`)
+	assert.NoError(err)
+
+	it, err := path.Resolve(&Instance{}, DirFS(root))
+	assert.NoError(err)
+
+	types := map[string]interface{}{}
+	for !it.Done() {
+		instance, err := it.Next()
+		assert.NoError(err)
+		types[instance.Vars["file.name"].(string)] = instance.Vars["file.type"]
+	}
+	assert.Equal(map[string]interface{}{"passwd": "file", "passwd.link": "symlink"}, types)
+}
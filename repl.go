@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle"
+	"github.com/alecthomas/repr"
+)
+
+const replHelp = `Enter an expression, including (let NAME = VALUE in BODY), to evaluate it or one of:
+  let NAME = EXPRESSION  assigns the result of an expression to a variable
+  :ast EXPRESSION        prints the syntax tree of an expression
+  :type EXPRESSION       prints the Go type of the result of an expression
+  :load FILE             reads variables from a JSON or YAML file
+  :vars                  lists variables of the session
+  :history               lists previous inputs, kept across sessions with --history
+  :help                  prints this help
+  :quit                  ends the session
+`
+
+const replPrompt = "> "
+
+var replVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// REPL evaluates expressions read line by line against a session instance
+type REPL struct {
+	// Instance holds functions and variables of the session
+	Instance *Instance
+	// History lists previous inputs of the session
+	History []string
+	// HistoryFile is a file inputs are appended to when set, see LoadHistory
+	HistoryFile string
+
+	out io.Writer
+}
+
+// NewREPL returns a REPL for an instance writing results and diagnostics to out
+func NewREPL(instance *Instance, out io.Writer) *REPL {
+	if instance.Vars == nil {
+		instance.Vars = VarMap{}
+	}
+	return &REPL{
+		Instance: instance,
+		out:      out,
+	}
+}
+
+// LoadHistory reads inputs of earlier sessions from a file, which doesn't need to exist, and
+// appends inputs of the session to it
+func (r *REPL) LoadHistory(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			r.History = append(r.History, line)
+		}
+	}
+	r.HistoryFile = filename
+	return nil
+}
+
+// saveHistory appends an input to the history file, if any
+func (r *REPL) saveHistory(line string) {
+	if r.HistoryFile == "" {
+		return
+	}
+	f, err := os.OpenFile(r.HistoryFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err == nil {
+		_, err = fmt.Fprintln(f, line)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		// Report the failure once rather than for every input
+		fmt.Fprintf(r.out, "error: failed to save history: %s\n", err)
+		r.HistoryFile = ""
+	}
+}
+
+// Run reads and executes lines until the end of input or a :quit command
+func (r *REPL) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.out, replPrompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+		if !r.Execute(scanner.Text()) {
+			return nil
+		}
+	}
+}
+
+// Execute executes a line of input and reports whether the session continues
+func (r *REPL) Execute(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+	r.History = append(r.History, line)
+	r.saveHistory(line)
+	// Each input is a new evaluation resolving variables afresh
+	r.Instance.ResetResolved()
+
+	command, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		command, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch command {
+	case ":quit", ":q":
+		return false
+	case ":help":
+		fmt.Fprint(r.out, replHelp)
+	case ":history":
+		for i, input := range r.History[:len(r.History)-1] {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, input)
+		}
+	case ":vars":
+		names := make([]string, 0, len(r.Instance.Vars))
+		for name := range r.Instance.Vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			r.printValue(name+" = ", r.Instance.Vars[name])
+		}
+	case ":load":
+		if err := loadVars(arg, r.Instance.Vars); err != nil {
+			fmt.Fprintf(r.out, "error: %s\n", err)
+		}
+	case ":ast":
+		if expr, ok := r.parse(arg, line); ok {
+			fmt.Fprintln(r.out, repr.String(expr, repr.Indent("  "), repr.OmitEmpty(true)))
+		}
+	case ":type":
+		if value, ok := r.evaluate(arg, line); ok {
+			fmt.Fprintf(r.out, "%T\n", value)
+		}
+	case "let":
+		var name, expression string
+		i := strings.IndexByte(arg, '=')
+		if i >= 0 {
+			name, expression = strings.TrimSpace(arg[:i]), strings.TrimSpace(arg[i+1:])
+		}
+
+		// An assignment takes precedence over a let expression, so that let ok = port in [22, 80]
+		// assigns the membership test. A let expression is evaluated without assigning its
+		// bindings to the session.
+		isAssignment := i >= 0 && replVariableName.MatchString(name)
+		if isAssignment {
+			_, err := ParseExpression(expression)
+			isAssignment = err == nil
+		}
+		if expr, err := ParseExpression(line); err == nil && !isAssignment {
+			if value, err := expr.Evaluate(r.Instance); err != nil {
+				r.printError(err, line, line)
+			} else {
+				r.printValue("", value)
+			}
+			break
+		}
+		if i < 0 {
+			fmt.Fprintln(r.out, "error: expecting let NAME = EXPRESSION")
+			break
+		}
+		if !replVariableName.MatchString(name) {
+			fmt.Fprintf(r.out, "error: invalid variable name %q\n", name)
+			break
+		}
+		if value, ok := r.evaluate(expression, line); ok {
+			r.Instance.Vars[name] = value
+			r.printValue(name+" = ", value)
+		}
+	default:
+		if strings.HasPrefix(command, ":") {
+			fmt.Fprintf(r.out, "error: unknown command %s, see :help\n", command)
+			break
+		}
+		if value, ok := r.evaluate(line, line); ok {
+			r.printValue("", value)
+		}
+	}
+	return true
+}
+
+// parse parses an expression, which is a part of a line, printing diagnostics for errors
+func (r *REPL) parse(expression, line string) (*Expression, bool) {
+	expr, err := ParseExpression(expression)
+	if err != nil {
+		r.printError(err, expression, line)
+		return nil, false
+	}
+	return expr, true
+}
+
+// evaluate parses and evaluates an expression, which is a part of a line, printing diagnostics for errors
+func (r *REPL) evaluate(expression, line string) (interface{}, bool) {
+	expr, ok := r.parse(expression, line)
+	if !ok {
+		return nil, false
+	}
+	value, err := expr.Evaluate(r.Instance)
+	if err != nil {
+		r.printError(err, expression, line)
+		return nil, false
+	}
+	return value, true
+}
+
+func (r *REPL) printValue(prefix string, value interface{}) {
+	if s, ok := value.(string); ok {
+		fmt.Fprintf(r.out, "%s%q (%T)\n", prefix, s, value)
+		return
+	}
+	fmt.Fprintf(r.out, "%s%v (%T)\n", prefix, value, value)
+}
+
+// printError prints an error marking its position in the line when known
+func (r *REPL) printError(err error, expression, line string) {
+	if parseErr, ok := err.(participle.Error); ok {
+		column := strings.Index(line, expression) + parseErr.Token().Pos.Column
+		fmt.Fprintf(r.out, "  %s\n  %s^\n", line, strings.Repeat(" ", column-1))
+	}
+	fmt.Fprintf(r.out, "error: %s\n", err)
+}
+
+func runREPL(args []string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	flags, vars := newVarsFlagSet("repl", stderr)
+	history := flags.String("history", "", "file keeping inputs across sessions")
+	if err := flags.Parse(args); err != nil {
+		return exitError, err
+	}
+	if flags.NArg() != 0 {
+		return exitError, fmt.Errorf("repl expects no arguments")
+	}
+	instance, err := vars.instance()
+	if err != nil {
+		return exitError, err
+	}
+
+	repl := NewREPL(instance, stdout)
+	if *history != "" {
+		if err := repl.LoadHistory(*history); err != nil {
+			return exitError, err
+		}
+	}
+	if err := repl.Run(stdin); err != nil {
+		return exitError, err
+	}
+	return exitPassed, nil
+}
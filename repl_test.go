@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+var prompts = regexp.MustCompile(`(?m)^(` + regexp.QuoteMeta(replPrompt) + `)+`)
+
+func TestREPL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "expressionist")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	varsFile := filepath.Join(dir, "vars.json")
+	assert.NoError(t, ioutil.WriteFile(varsFile, []byte(`{"file": {"owner": "root"}}`), 0644))
+
+	tests := []struct {
+		name         string
+		input        string
+		expectOutput string
+	}{
+		{
+			name:         "expression",
+			input:        `"abc" + "def"`,
+			expectOutput: "\"abcdef\" (string)\n",
+		},
+		{
+			name:         "let",
+			input:        "let mode = 0644\nmode & 022 == 0",
+			expectOutput: "mode = 420 (uint64)\ntrue (bool)\n",
+		},
+		{
+			name:         "let overrides variable",
+			input:        "let x = 1\nlet x = x == 1\nx",
+			expectOutput: "x = 1 (int64)\nx = true (bool)\ntrue (bool)\n",
+		},
+		{
+			name:         "let expression",
+			input:        "let x = 2 in x | 1\nx",
+			expectOutput: "3 (int64)\n  x\n  ^\nerror: 1:1: unknown variable \"x\"\n",
+		},
+		{
+			name:         "let assigns membership test",
+			input:        "let port = 22\nlet ok = port in [22, 80]\nok",
+			expectOutput: "port = 22 (int64)\nok = true (bool)\ntrue (bool)\n",
+		},
+		{
+			name:         "let expression in parentheses",
+			input:        "(let x = 22 in x in [22, 80])\nx",
+			expectOutput: "true (bool)\n  x\n  ^\nerror: 1:1: unknown variable \"x\"\n",
+		},
+		{
+			name:         "let invalid name",
+			input:        "let 1x = 2",
+			expectOutput: "error: invalid variable name \"1x\"\n",
+		},
+		{
+			name:         "let missing expression",
+			input:        "let x",
+			expectOutput: "error: expecting let NAME = EXPRESSION\n",
+		},
+		{
+			name:         "type",
+			input:        ":type 1h",
+			expectOutput: "time.Duration\n",
+		},
+		{
+			name:  "ast",
+			input: ":ast x",
+			expectOutput: `&main.Expression{
+  Pos: Position{Filename: "", Offset: 0, Line: 1, Column: 1},
+  Comparison: &main.Comparison{
+    Pos: Position{Filename: "", Offset: 0, Line: 1, Column: 1},
+    Term: &main.Term{
+      Pos: Position{Filename: "", Offset: 0, Line: 1, Column: 1},
+      Unary: &main.Unary{
+        Pos: Position{Filename: "", Offset: 0, Line: 1, Column: 1},
+        Value: &main.Value{
+          Pos: Position{Filename: "", Offset: 0, Line: 1, Column: 1},
+          Variable: &"x",
+        },
+      },
+    },
+  },
+}
+`,
+		},
+		{
+			name:         "load",
+			input:        ":load " + varsFile + "\nfile.owner",
+			expectOutput: "\"root\" (string)\n",
+		},
+		{
+			name:         "load missing file",
+			input:        ":load " + filepath.Join(dir, "missing.json"),
+			expectOutput: "error: open " + filepath.Join(dir, "missing.json") + ": no such file or directory\n",
+		},
+		{
+			name:         "vars",
+			input:        "let b = 2\nlet a = \"1\"\n:vars",
+			expectOutput: "b = 2 (int64)\na = \"1\" (string)\na = \"1\" (string)\nb = 2 (int64)\n",
+		},
+		{
+			name:         "history",
+			input:        "1\n\n2\n:history",
+			expectOutput: "1 (int64)\n2 (int64)\n   1  1\n   2  2\n",
+		},
+		{
+			name:         "syntax error",
+			input:        "let x = 1 >",
+			expectOutput: "  let x = 1 >\n             ^\nerror: 1:4: unexpected token \"<EOF>\" (expected \"[\" | \"!\" | \"-\" | \"^\" | <size> | \"-\" | \"+\" | <duration> | \"-\" | \"+\" | <hex> | <octal> | <decimal> | \"-\" | \"+\" | <string> | <rawstring> | <ident> | <ident> | \"(\")\n",
+		},
+		{
+			name:         "evaluation error",
+			input:        `1 == unknown`,
+			expectOutput: "  1 == unknown\n       ^\nerror: 1:6: unknown variable \"unknown\"\n",
+		},
+		{
+			name:         "unknown command",
+			input:        ":bogus",
+			expectOutput: "error: unknown command :bogus, see :help\n",
+		},
+		{
+			name:         "quit",
+			input:        "1\n:quit\n2",
+			expectOutput: "1 (int64)\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			var out bytes.Buffer
+			repl := NewREPL(&Instance{}, &out)
+			assert.NoError(repl.Run(strings.NewReader(test.input + "\n:quit\n")))
+			assert.Equal(test.expectOutput, prompts.ReplaceAllString(out.String(), ""))
+		})
+	}
+}
+
+func TestREPLResolvesVariablesPerInput(t *testing.T) {
+	assert := assert.New(t)
+	var calls int64
+	instance := &Instance{
+		Resolver: VariableResolverFunc(func(instance *Instance, name string) (interface{}, bool, error) {
+			calls++
+			return calls, name == "uptime", nil
+		}),
+	}
+	var out bytes.Buffer
+	repl := NewREPL(instance, &out)
+	assert.NoError(repl.Run(strings.NewReader("uptime + uptime\nuptime\n:quit\n")))
+	assert.Equal("2 (int64)\n2 (int64)\n", prompts.ReplaceAllString(out.String(), ""))
+}
+
+func TestREPLCommand(t *testing.T) {
+	assert := assert.New(t)
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"repl", "--var", "x=1"}, strings.NewReader("x + \"a\"\n"), &stdout, &stderr)
+	assert.Equal(exitPassed, status)
+	assert.Equal(">   x + \"a\"\n  ^\nerror: 1:1: rhs of + must be an integer\n> \n", stdout.String())
+
+	stdout.Reset()
+	status = run([]string{"repl", "--var", "name=root"}, strings.NewReader("upper(name)\n"), &stdout, &stderr)
+	assert.Equal(exitPassed, status)
+	assert.Equal("> \"ROOT\" (string)\n> \n", stdout.String())
+}
+
+func TestREPLCommandArguments(t *testing.T) {
+	assert := assert.New(t)
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"repl", "x"}, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(exitError, status)
+	assert.Equal("expressionist: repl expects no arguments\n", stderr.String())
+}
+
+func TestREPLHistoryFile(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "expressionist")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	history := filepath.Join(dir, "history")
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"repl", "--history", history}, strings.NewReader("1 + 1\n\nlet x = 2\n"), &stdout, &stderr)
+	assert.Equal(exitPassed, status)
+	assert.Empty(stderr.String())
+
+	data, err := ioutil.ReadFile(history)
+	assert.NoError(err)
+	assert.Equal("1 + 1\nlet x = 2\n", string(data))
+
+	stdout.Reset()
+	status = run([]string{"repl", "--history", history}, strings.NewReader("x\n:history\n"), &stdout, &stderr)
+	assert.Equal(exitPassed, status)
+	assert.Equal(">   x\n  ^\nerror: 1:1: unknown variable \"x\"\n>    1  1 + 1\n   2  let x = 2\n   3  x\n> \n", stdout.String())
+
+	data, err = ioutil.ReadFile(history)
+	assert.NoError(err)
+	assert.Equal("1 + 1\nlet x = 2\nx\n:history\n", string(data))
+}
+
+func TestREPLHistoryFileError(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "expressionist")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	var out bytes.Buffer
+	repl := NewREPL(&Instance{}, &out)
+	assert.NoError(repl.LoadHistory(filepath.Join(dir, "missing", "history")))
+	repl.Execute("1")
+	repl.Execute("2")
+	assert.Contains(out.String(), "error: failed to save history: open "+filepath.Join(dir, "missing", "history"))
+	assert.Equal(1, strings.Count(out.String(), "failed to save history"))
+	assert.Equal([]string{"1", "2"}, repl.History)
+}
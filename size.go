@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/alecthomas/participle/lexer"
+)
+
+// Size is a number with a size unit, such as 512MB, 2GiB or 1.5GiB, evaluating to bytes.
+// Fractional sizes must amount to a whole number of bytes, and a plain B unit denotes bytes
+// while hexadecimal literals such as 0x1B remain integers.
+type Size int64
+
+// Capture parses a size literal
+func (s *Size) Capture(values []string) error {
+	size, err := parseSize(strings.Join(values, ""))
+	if err != nil {
+		return err
+	}
+	*s = Size(size)
+	return nil
+}
+
+var sizeUnits = map[string]int64{
+	"B":   1,
+	"kB":  1000,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"PB":  1000 * 1000 * 1000 * 1000 * 1000,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+	"PiB": 1 << 50,
+}
+
+// parseSize parses a number with an optional fraction followed by a decimal or binary size unit
+func parseSize(s string) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	digits, suffix := splitDigits(strings.TrimLeft(s, "+-"))
+	if digits == "" {
+		return 0, errors.New("expecting a number")
+	}
+	var fraction string
+	if strings.HasPrefix(suffix, ".") {
+		if fraction, suffix = splitDigits(suffix[1:]); fraction == "" {
+			return 0, errors.New("expecting a fraction")
+		}
+	}
+	unit, ok := sizeUnits[suffix]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %s", suffix)
+	}
+
+	// Sizes are computed exactly as (digits.fraction * 10^len(fraction)) * unit / 10^len(fraction)
+	n, _ := new(big.Int).SetString(digits+fraction, 10)
+	n.Mul(n, big.NewInt(unit))
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(len(fraction))), nil)
+	size, remainder := n.QuoRem(n, scale, new(big.Int))
+	if remainder.Sign() != 0 {
+		return 0, errors.New("size is not a whole number of bytes")
+	}
+	if !size.IsInt64() {
+		return 0, errors.New("size overflows")
+	}
+	if negative {
+		return -size.Int64(), nil
+	}
+	return size.Int64(), nil
+}
+
+// checkSize reports invalid size literals at parse time
+func checkSize(token lexer.Token) (lexer.Token, error) {
+	if _, err := parseSize(token.Value); err != nil {
+		return token, lexer.ErrorWithTokenf(token, `invalid size "%s": %s`, token.Value, err)
+	}
+	return token, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        string
+		expectSize  int64
+		expectError string
+	}{
+		{
+			name:       "bytes",
+			size:       "512B",
+			expectSize: 512,
+		},
+		{
+			name:       "negative",
+			size:       "-1KiB",
+			expectSize: -1024,
+		},
+		{
+			name:       "kilobytes",
+			size:       "4kB",
+			expectSize: 4000,
+		},
+		{
+			name:       "megabytes",
+			size:       "512MB",
+			expectSize: 512000000,
+		},
+		{
+			name:       "kibibytes",
+			size:       "1KiB",
+			expectSize: 1024,
+		},
+		{
+			name:       "gibibytes",
+			size:       "2GiB",
+			expectSize: 2147483648,
+		},
+		{
+			name:       "pebibytes",
+			size:       "8PiB",
+			expectSize: 8 << 50,
+		},
+		{
+			name:       "fractional gibibytes",
+			size:       "1.5GiB",
+			expectSize: 1536 << 20,
+		},
+		{
+			name:       "fractional kilobytes",
+			size:       "-2.25kB",
+			expectSize: -2250,
+		},
+		{
+			name:        "fractional bytes",
+			size:        "1.5B",
+			expectError: "size is not a whole number of bytes",
+		},
+		{
+			name:        "missing fraction",
+			size:        "1.KiB",
+			expectError: "expecting a fraction",
+		},
+		{
+			name:        "unknown unit",
+			size:        "1kiB",
+			expectError: "unknown unit kiB",
+		},
+		{
+			name:        "overflow",
+			size:        "8192PiB",
+			expectError: "size overflows",
+		},
+		{
+			name:        "number overflow",
+			size:        "99999999999999999999B",
+			expectError: "size overflows",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			size, err := parseSize(test.size)
+			if test.expectError != "" {
+				assert.EqualError(err, test.expectError)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectSize, size)
+			}
+		})
+	}
+}
+
+func TestParseSizeError(t *testing.T) {
+	assert := assert.New(t)
+	expr, err := ParseExpression("file.size > 10000PiB")
+
+	assert.Nil(expr)
+	assert.EqualError(err, `1:13: invalid size "10000PiB": size overflows`)
+
+	expr, err = ParseExpression("file.size > 0.1KiB")
+	assert.Nil(expr)
+	assert.EqualError(err, `1:13: invalid size "0.1KiB": size is not a whole number of bytes`)
+}
+
+func TestEvalSize(t *testing.T) {
+	instanceTests{
+		{
+			name:         "size literal",
+			expression:   `1GiB`,
+			expectResult: int64(1073741824),
+		},
+		{
+			name:         "size comparison",
+			expression:   `file.size > 512MB`,
+			vars:         VarMap{"file.size": int64(1 << 30)},
+			expectResult: true,
+		},
+		{
+			name:         "size bit operations",
+			expression:   `1MiB | 1KiB`,
+			expectResult: int64(1049600),
+		},
+		{
+			name:         "negative size",
+			expression:   `-1KiB`,
+			expectResult: int64(-1024),
+		},
+		{
+			name:         "size and decimal",
+			expression:   `1024KiB == 1048576`,
+			expectResult: true,
+		},
+		{
+			name:         "fractional size",
+			expression:   `1.5GiB == 1536MiB && 0.5KiB == 512`,
+			expectResult: true,
+		},
+		{
+			name:         "bytes and hexadecimal literals",
+			expression:   `1B == 1 && 0x1B == 27 && 0x1b == 27 && 0xB == 11 && 01B == 1 && B == 2`,
+			vars:         VarMap{"B": 2},
+			expectResult: true,
+		},
+		{
+			name:         "size does not shadow durations",
+			expression:   `1m`,
+			expectResult: time.Minute,
+		},
+	}.Run(t)
+}
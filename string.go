@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StringFunctions returns functions operating on strings:
+//   - lower(s), upper(s) change the case of a string
+//   - trim(s[, cutset]) removes leading and trailing whitespace or characters of cutset
+//   - split(s, sep) returns an array of substrings separated by sep
+//   - join(array, sep) concatenates an array of strings using sep
+//   - contains(s, substr), startsWith(s, prefix), endsWith(s, suffix) test for substrings
+//   - replace(s, old, new) replaces all occurrences of old in s
+//   - substr(s, start[, length]) returns a substring using character offsets
+//   - len(s) returns the number of characters in a string or elements in an array
+//   - format(fmt, args...) replaces "{}" and positional "{0}" placeholders of fmt with args
+//   - sprintf(fmt, args...) formats args according to fmt.Sprintf verbs
+func StringFunctions() FunctionMap {
+	return FunctionMap{
+		"lower": stringFunction(strings.ToLower),
+		"upper": stringFunction(strings.ToUpper),
+		"trim": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 1, 2); err != nil {
+				return nil, err
+			}
+			s, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			if len(args) == 1 {
+				return strings.TrimSpace(s), nil
+			}
+			cutset, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return strings.Trim(s, cutset), nil
+		},
+		"split": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			s, sep, err := stringArgPair(args)
+			if err != nil {
+				return nil, err
+			}
+			var result []interface{}
+			for _, part := range strings.Split(s, sep) {
+				result = append(result, part)
+			}
+			return result, nil
+		},
+		"join": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 2, 2); err != nil {
+				return nil, err
+			}
+			array, ok := args[0].([]interface{})
+			if !ok {
+				return nil, errors.New("expecting an array for argument 1")
+			}
+			sep, err := stringArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			parts := make([]string, 0, len(array))
+			for i := range array {
+				part, err := stringArg(array, i)
+				if err != nil {
+					return nil, errors.New("expecting an array of strings for argument 1")
+				}
+				parts = append(parts, part)
+			}
+			return strings.Join(parts, sep), nil
+		},
+		"contains":   stringPredicate(strings.Contains),
+		"startsWith": stringPredicate(strings.HasPrefix),
+		"endsWith":   stringPredicate(strings.HasSuffix),
+		"replace": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 3, 3); err != nil {
+				return nil, err
+			}
+			var s [3]string
+			for i := range s {
+				var err error
+				if s[i], err = stringArg(args, i); err != nil {
+					return nil, err
+				}
+			}
+			return strings.ReplaceAll(s[0], s[1], s[2]), nil
+		},
+		"substr": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 2, 3); err != nil {
+				return nil, err
+			}
+			s, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			runes := []rune(s)
+			start, err := intArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			if start < 0 || start > int64(len(runes)) {
+				return nil, fmt.Errorf("start %d out of range for string of length %d", start, len(runes))
+			}
+			end := int64(len(runes))
+			if len(args) == 3 {
+				length, err := intArg(args, 2)
+				if err != nil {
+					return nil, err
+				}
+				if length < 0 || length > end-start {
+					return nil, fmt.Errorf("length %d out of range for string of length %d", length, len(runes))
+				}
+				end = start + length
+			}
+			return string(runes[start:end]), nil
+		},
+		"len": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			switch arg := args[0].(type) {
+			case string:
+				return int64(utf8.RuneCountInString(arg)), nil
+			case []interface{}:
+				return int64(len(arg)), nil
+			default:
+				return nil, errors.New("expecting a string or an array for argument 1")
+			}
+		},
+		"format": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 1, -1); err != nil {
+				return nil, err
+			}
+			format, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return formatPlaceholders(format, args[1:])
+		},
+		"sprintf": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 1, -1); err != nil {
+				return nil, err
+			}
+			format, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return fmt.Sprintf(format, args[1:]...), nil
+		},
+	}
+}
+
+func stringFunction(fn func(s string) string) Function {
+	return func(instance *Instance, args ...interface{}) (interface{}, error) {
+		if err := checkArgCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		s, err := stringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return fn(s), nil
+	}
+}
+
+func stringPredicate(fn func(s, substr string) bool) Function {
+	return func(instance *Instance, args ...interface{}) (interface{}, error) {
+		s, substr, err := stringArgPair(args)
+		if err != nil {
+			return nil, err
+		}
+		return fn(s, substr), nil
+	}
+}
+
+// formatPlaceholders replaces "{}" placeholders with subsequent args and "{N}" with args at index N,
+// while "{{" and "}}" produce literal braces
+func formatPlaceholders(format string, args []interface{}) (string, error) {
+	var (
+		b    strings.Builder
+		next int
+	)
+	for i := 0; i < len(format); i++ {
+		switch c := format[i]; {
+		case c == '{' && strings.HasPrefix(format[i:], "{{"):
+			b.WriteByte('{')
+			i++
+		case c == '}' && strings.HasPrefix(format[i:], "}}"):
+			b.WriteByte('}')
+			i++
+		case c == '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated placeholder at offset %d", i)
+			}
+			index := next
+			if placeholder := format[i+1 : i+end]; placeholder != "" {
+				n, err := strconv.Atoi(placeholder)
+				if err != nil {
+					return "", fmt.Errorf(`invalid placeholder "{%s}"`, placeholder)
+				}
+				index = n
+			} else {
+				next++
+			}
+			if index < 0 || index >= len(args) {
+				return "", fmt.Errorf("missing argument for placeholder %d", index)
+			}
+			fmt.Fprint(&b, args[index])
+			i += end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// checkArgCount validates the number of function arguments, max of -1 allows any number of arguments
+func checkArgCount(args []interface{}, min, max int) error {
+	switch {
+	case len(args) >= min && (max < 0 || len(args) <= max):
+		return nil
+	case min == max:
+		return fmt.Errorf("expecting %s, got %d", pluralArguments(min), len(args))
+	case max < 0:
+		return fmt.Errorf("expecting at least %s, got %d", pluralArguments(min), len(args))
+	default:
+		return fmt.Errorf("expecting %d to %d arguments, got %d", min, max, len(args))
+	}
+}
+
+func pluralArguments(count int) string {
+	if count == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", count)
+}
+
+func stringArg(args []interface{}, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", fmt.Errorf("expecting a string for argument %d", i+1)
+	}
+	return s, nil
+}
+
+func stringArgPair(args []interface{}) (string, string, error) {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return "", "", err
+	}
+	first, err := stringArg(args, 0)
+	if err != nil {
+		return "", "", err
+	}
+	second, err := stringArg(args, 1)
+	if err != nil {
+		return "", "", err
+	}
+	return first, second, nil
+}
+
+func intArg(args []interface{}, i int) (int64, error) {
+	switch arg := args[i].(type) {
+	case int64:
+		return arg, nil
+	case uint64:
+		return int64(arg), nil
+	default:
+		return 0, fmt.Errorf("expecting an integer for argument %d", i+1)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStringFunctions(t *testing.T) {
+	functions := StringFunctions()
+	instanceTests{
+		{
+			name:         "lower",
+			expression:   `lower("ROOT")`,
+			functions:    functions,
+			expectResult: "root",
+		},
+		{
+			name:         "upper",
+			expression:   `upper("root")`,
+			functions:    functions,
+			expectResult: "ROOT",
+		},
+		{
+			name:         "trim",
+			expression:   `trim("  root \t")`,
+			functions:    functions,
+			expectResult: "root",
+		},
+		{
+			name:         "trim cutset",
+			expression:   `trim("--root--", "-")`,
+			functions:    functions,
+			expectResult: "root",
+		},
+		{
+			name:         "split",
+			expression:   `split("rw,nodev,nosuid", ",")`,
+			functions:    functions,
+			expectResult: []interface{}{"rw", "nodev", "nosuid"},
+		},
+		{
+			name:         "split in",
+			expression:   `"nodev" in split(options, ",")`,
+			vars:         VarMap{"options": "rw,nodev,nosuid"},
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "join",
+			expression:   `join(split("a b c", " "), ",")`,
+			functions:    functions,
+			expectResult: "a,b,c",
+		},
+		{
+			name:         "contains",
+			expression:   `contains("PermitRootLogin no", "Root")`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "starts with",
+			expression:   `startsWith(path, "/etc/")`,
+			vars:         VarMap{"path": "/etc/passwd"},
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "ends with",
+			expression:   `endsWith(path, ".conf")`,
+			vars:         VarMap{"path": "/etc/passwd"},
+			functions:    functions,
+			expectResult: false,
+		},
+		{
+			name:         "replace",
+			expression:   `replace("a-b-c", "-", "+")`,
+			functions:    functions,
+			expectResult: "a+b+c",
+		},
+		{
+			name:         "substr",
+			expression:   `substr("héllo world", 1, 4)`,
+			functions:    functions,
+			expectResult: "éllo",
+		},
+		{
+			name:         "substr to end",
+			expression:   `substr("héllo world", 6)`,
+			functions:    functions,
+			expectResult: "world",
+		},
+		{
+			name:         "len string",
+			expression:   `len("héllo") == 5`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "len array",
+			expression:   `len(split("a,b", ","))`,
+			functions:    functions,
+			expectResult: int64(2),
+		},
+		{
+			name:         "format",
+			expression:   `format("{} owns {{{}}} with mode {2}", "root", "/etc/passwd", 0644)`,
+			functions:    functions,
+			expectResult: "root owns {/etc/passwd} with mode 420",
+		},
+		{
+			name:         "sprintf",
+			expression:   `sprintf("%s:%04o", "root", 0644)`,
+			functions:    functions,
+			expectResult: "root:0644",
+		},
+		{
+			name:        "invalid argument type",
+			expression:  `lower(1)`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "lower()" failed: expecting a string for argument 1`),
+		},
+		{
+			name:        "invalid argument count",
+			expression:  `x && contains("abc")`,
+			vars:        VarMap{"x": true},
+			functions:   functions,
+			expectError: newLexerError(5, `call to "contains()" failed: expecting 2 arguments, got 1`),
+		},
+		{
+			name:        "invalid argument range",
+			expression:  `trim()`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "trim()" failed: expecting 1 to 2 arguments, got 0`),
+		},
+		{
+			name:        "invalid join array",
+			expression:  `join(split("a", ","), 1)`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "join()" failed: expecting a string for argument 2`),
+		},
+		{
+			name:        "invalid substr start",
+			expression:  `substr("abc", "1")`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "substr()" failed: expecting an integer for argument 2`),
+		},
+		{
+			name:        "substr out of range",
+			expression:  `substr("abc", 1, 5)`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "substr()" failed: length 5 out of range for string of length 3`),
+		},
+		{
+			name:        "substr length overflow",
+			expression:  `substr("abc", 1, 9223372036854775807)`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "substr()" failed: length 9223372036854775807 out of range for string of length 3`),
+		},
+		{
+			name:        "format missing argument",
+			expression:  `format("{} {}", "a")`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "format()" failed: missing argument for placeholder 1`),
+		},
+		{
+			name:        "format invalid placeholder",
+			expression:  `format("{a}", "a")`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "format()" failed: invalid placeholder "{a}"`),
+		},
+		{
+			name:        "sprintf missing format",
+			expression:  `sprintf()`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "sprintf()" failed: expecting at least 1 argument, got 0`),
+		},
+	}.Run(t)
+}
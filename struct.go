@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"reflect"
+	"time"
+)
+
+var (
+	durationType   = reflect.TypeOf(time.Duration(0))
+	timeType       = reflect.TypeOf(time.Time{})
+	ipType         = reflect.TypeOf(net.IP{})
+	ipNetType      = reflect.TypeOf(net.IPNet{})
+	comparableType = reflect.TypeOf((*Comparable)(nil)).Elem()
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// InstanceFromStruct returns an instance exposing exported fields of a struct or a pointer to a
+// struct as variables and its exported methods as functions, so that expressions can be evaluated
+// directly on Go values:
+//   - variables are named after fields or their expr:"name" tags, fields tagged expr:"-" are skipped
+//   - fields and methods of nested structs are prefixed with the name of the struct field and a dot,
+//     while those of untagged embedded structs are promoted
+//   - integers are converted to int64 or uint64 as with coerceIntegers, named string and boolean
+//     types to string and bool, and slices to arrays, while times, durations, IP addresses,
+//     networks and Comparable values are kept
+//   - methods returning a value and optionally an error are exposed as functions, converting
+//     arguments to parameter types; pointer methods are included when a pointer is passed
+//   - pointers to structs leading back to an enclosing struct are rejected with an error
+func InstanceFromStruct(v interface{}) (*Instance, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expecting a struct or a pointer to a struct, got %T", v)
+	}
+
+	instance := &Instance{
+		Functions: FunctionMap{},
+		Vars:      VarMap{},
+	}
+	if err := bindStruct(instance, "", value, enclosingStructs(nil, value)); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+// bindStruct adds fields and methods of a struct to an instance naming them with a prefix,
+// enclosing holds structs being bound to detect cycles of pointers
+func bindStruct(instance *Instance, prefix string, v reflect.Value, enclosing []structAddr) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := field.Tag.Get("expr")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = field.Name
+		}
+
+		value := v.Field(i)
+		nested := value
+		if nested.Kind() == reflect.Ptr && nested.Type().Elem().Kind() == reflect.Struct {
+			if nested.IsNil() {
+				if !isScalarStruct(nested.Type().Elem()) {
+					continue
+				}
+			} else {
+				nested = nested.Elem()
+				for _, s := range enclosing {
+					if s == (structAddr{nested.UnsafeAddr(), nested.Type()}) {
+						return fmt.Errorf("cycle of pointers at field %s%s", prefix, name)
+					}
+				}
+			}
+		}
+		if nested.Kind() == reflect.Struct && !isScalarStruct(nested.Type()) {
+			nestedPrefix := prefix + name + "."
+			if field.Anonymous && tag == "" {
+				nestedPrefix = prefix
+			}
+			if err := bindStruct(instance, nestedPrefix, nested, enclosingStructs(enclosing, nested)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		instance.Vars[prefix+name] = structValue(value)
+	}
+
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	for i := 0; i < v.NumMethod(); i++ {
+		if fn, ok := methodFunction(v.Method(i)); ok {
+			instance.Functions[prefix+v.Type().Method(i).Name] = fn
+		}
+	}
+	return nil
+}
+
+// structAddr identifies a struct in memory, the type tells apart a struct from its first field
+type structAddr struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// enclosingStructs returns enclosing structs of fields of a struct
+func enclosingStructs(enclosing []structAddr, v reflect.Value) []structAddr {
+	if !v.CanAddr() {
+		return enclosing
+	}
+	return append(enclosing[:len(enclosing):len(enclosing)], structAddr{v.UnsafeAddr(), v.Type()})
+}
+
+// isScalarStruct reports whether values of a struct type are exposed as a single variable
+func isScalarStruct(t reflect.Type) bool {
+	return t == timeType || t == ipNetType || t.Implements(comparableType) || reflect.PtrTo(t).Implements(comparableType)
+}
+
+// structValue converts a field or a method result to a value supported by expressions
+func structValue(v reflect.Value) interface{} {
+	switch v.Type() {
+	case durationType, timeType, ipType:
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Slice, reflect.Array:
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = structValue(v.Index(i))
+		}
+		return values
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return structValue(v.Elem())
+	case reflect.Struct:
+		// Networks and Comparable values implemented by pointers are expected as pointers
+		if v.Type() == ipNetType || reflect.PtrTo(v.Type()).Implements(comparableType) {
+			if v.CanAddr() {
+				return v.Addr().Interface()
+			}
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			return p.Interface()
+		}
+	}
+	return coerceIntegers(v.Interface())
+}
+
+// methodFunction returns a function calling a method returning a value and optionally an error
+func methodFunction(method reflect.Value) (Function, bool) {
+	t := method.Type()
+	switch {
+	case t.NumOut() == 1 && t.Out(0) != errorType:
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+	default:
+		return nil, false
+	}
+
+	return func(instance *Instance, args ...interface{}) (interface{}, error) {
+		in, err := methodArgs(t, args)
+		if err != nil {
+			return nil, err
+		}
+		out := method.Call(in)
+		if len(out) == 2 && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+		return structValue(out[0]), nil
+	}, true
+}
+
+// methodArgs converts arguments of a function call to parameters of a method
+func methodArgs(t reflect.Type, args []interface{}) ([]reflect.Value, error) {
+	min, max := t.NumIn(), t.NumIn()
+	if t.IsVariadic() {
+		min, max = min-1, -1
+	}
+	if err := checkArgCount(args, min, max); err != nil {
+		return nil, err
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var paramType reflect.Type
+		if t.IsVariadic() && i >= min {
+			paramType = t.In(min).Elem()
+		} else {
+			paramType = t.In(i)
+		}
+		value, ok := convertArg(arg, paramType)
+		if !ok {
+			return nil, fmt.Errorf("expecting %s for argument %d", paramType, i+1)
+		}
+		in[i] = value
+	}
+	return in, nil
+}
+
+// convertArg converts an argument to a parameter type, allowing conversion between integer types
+// for values in range, named string and boolean types and arrays to slices of convertible elements
+func convertArg(arg interface{}, t reflect.Type) (reflect.Value, bool) {
+	if arg == nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), true
+		}
+		return reflect.Value{}, false
+	}
+
+	v := reflect.ValueOf(arg)
+	switch {
+	case v.Type().AssignableTo(t):
+		return v, true
+	case isIntegerKind(v.Kind()) && isIntegerKind(t.Kind()):
+		if integerOverflows(v, t) {
+			return reflect.Value{}, false
+		}
+		return v.Convert(t), true
+	case v.Kind() == t.Kind() && (v.Kind() == reflect.String || v.Kind() == reflect.Bool):
+		return v.Convert(t), true
+	case v.Kind() == reflect.Slice && t.Kind() == reflect.Slice:
+		converted := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, ok := convertArg(v.Index(i).Interface(), t.Elem())
+			if !ok {
+				return reflect.Value{}, false
+			}
+			converted.Index(i).Set(elem)
+		}
+		return converted, true
+	}
+	return reflect.Value{}, false
+}
+
+// integerOverflows reports whether an integer value is out of range of an integer type
+func integerOverflows(v reflect.Value, t reflect.Type) bool {
+	target := reflect.Zero(t)
+	if isUnsignedKind(v.Kind()) {
+		u := v.Uint()
+		if isUnsignedKind(t.Kind()) {
+			return target.OverflowUint(u)
+		}
+		return u > math.MaxInt64 || target.OverflowInt(int64(u))
+	}
+	i := v.Int()
+	if isUnsignedKind(t.Kind()) {
+		return i < 0 || target.OverflowUint(uint64(i))
+	}
+	return target.OverflowInt(i)
+}
+
+func isUnsignedKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isIntegerKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
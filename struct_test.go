@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+type structOwner struct {
+	Name string `expr:"name"`
+	UID  uint32 `expr:"uid"`
+}
+
+func (o structOwner) IsRoot() bool {
+	return o.UID == 0
+}
+
+type StructMetadata struct {
+	Labels []string `expr:"labels"`
+}
+
+type structFile struct {
+	StructMetadata
+
+	Path     string        `expr:"path"`
+	Mode     os.FileMode   `expr:"mode"`
+	Size     int           `expr:"size"`
+	Age      time.Duration `expr:"age"`
+	Modified time.Time     `expr:"modified"`
+	Owner    structOwner   `expr:"owner"`
+	Group    *structOwner  `expr:"group"`
+	Version  *SemanticVersion
+	Addr     net.IP `expr:"addr"`
+	Link     *string
+	Secret   string `expr:"-"`
+
+	hidden string
+}
+
+func (f *structFile) HasPrefix(prefix string) bool {
+	return strings.HasPrefix(f.Path, prefix)
+}
+
+func (f *structFile) Perm(mask os.FileMode) os.FileMode {
+	return f.Mode.Perm() & mask
+}
+
+func (f *structFile) Head(n uint16) string {
+	if int(n) > len(f.Path) {
+		return f.Path
+	}
+	return f.Path[:n]
+}
+
+func (f *structFile) Contains(labels ...string) (bool, error) {
+	if len(labels) == 0 {
+		return false, errors.New("no labels")
+	}
+	for _, label := range labels {
+		if !f.hasLabel(label) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (f *structFile) Touch() {}
+
+func (f *structFile) hasLabel(label string) bool {
+	for _, l := range f.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+func newStructFile(t *testing.T) *structFile {
+	version, err := ParseSemanticVersion("1.2.3")
+	assert.NoError(t, err)
+	return &structFile{
+		StructMetadata: StructMetadata{Labels: []string{"config", "ssh"}},
+		Path:           "/etc/ssh/sshd_config",
+		Mode:           0644,
+		Size:           3 * 1024,
+		Age:            90 * time.Minute,
+		Modified:       time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+		Owner:          structOwner{Name: "root"},
+		Version:        version,
+		Addr:           net.ParseIP("10.0.0.1"),
+		Secret:         "s3cr3t",
+		hidden:         "hidden",
+	}
+}
+
+func TestInstanceFromStruct(t *testing.T) {
+	assert := assert.New(t)
+	instance, err := InstanceFromStruct(newStructFile(t))
+	assert.NoError(err)
+
+	assert.Equal(VarMap{
+		"labels":     []interface{}{"config", "ssh"},
+		"path":       "/etc/ssh/sshd_config",
+		"mode":       uint64(0644),
+		"size":       int64(3 * 1024),
+		"age":        90 * time.Minute,
+		"modified":   time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+		"owner.name": "root",
+		"owner.uid":  uint64(0),
+		"Version":    instance.Vars["Version"],
+		"addr":       net.ParseIP("10.0.0.1"),
+		"Link":       nil,
+	}, instance.Vars)
+	assert.IsType(&SemanticVersion{}, instance.Vars["Version"])
+
+	var funcs []string
+	for name := range instance.Functions {
+		funcs = append(funcs, name)
+	}
+	assert.ElementsMatch([]string{"HasPrefix", "Perm", "Head", "Contains", "owner.IsRoot"}, funcs)
+}
+
+func TestInstanceFromStructEvaluate(t *testing.T) {
+	file := newStructFile(t)
+	instance, err := InstanceFromStruct(file)
+	assert.NoError(t, err)
+	instance.Parent = &Instance{Functions: VersionFunctions()}
+	for name, fn := range TimeFunctions() {
+		instance.Parent.Functions[name] = fn
+	}
+
+	tests := []struct {
+		name         string
+		expression   string
+		expectResult interface{}
+		expectError  string
+	}{
+		{
+			name:         "fields",
+			expression:   `path == "/etc/ssh/sshd_config" && mode & 022 == 0 && size <= 4KiB && age > 1h`,
+			expectResult: true,
+		},
+		{
+			name:         "nested fields",
+			expression:   `owner.name == "root" && owner.uid == 0 && owner.IsRoot()`,
+			expectResult: true,
+		},
+		{
+			name:         "promoted fields",
+			expression:   `"ssh" in labels`,
+			expectResult: true,
+		},
+		{
+			name:         "scalar structs",
+			expression:   `modified < timestamp("2021-01-01T00:00:00Z") && Version >= semver("1.2.0") && addr == "10.0.0.1"`,
+			expectResult: true,
+		},
+		{
+			name:         "method",
+			expression:   `HasPrefix("/etc/") && Perm(0600) == 0600 && Head(4) == "/etc"`,
+			expectResult: true,
+		},
+		{
+			name:         "variadic method",
+			expression:   `Contains("ssh", "config") && !Contains("nginx")`,
+			expectResult: true,
+		},
+		{
+			name:        "method error",
+			expression:  `Contains()`,
+			expectError: `1:1: call to "Contains()" failed: no labels`,
+		},
+		{
+			name:        "method argument count",
+			expression:  `HasPrefix()`,
+			expectError: `1:1: call to "HasPrefix()" failed: expecting 1 argument, got 0`,
+		},
+		{
+			name:        "method argument type",
+			expression:  `HasPrefix(1)`,
+			expectError: `1:1: call to "HasPrefix()" failed: expecting string for argument 1`,
+		},
+		{
+			name:        "method argument overflow",
+			expression:  `Head(70000)`,
+			expectError: `1:1: call to "Head()" failed: expecting uint16 for argument 1`,
+		},
+		{
+			name:        "method negative argument",
+			expression:  `Head(-1)`,
+			expectError: `1:1: call to "Head()" failed: expecting uint16 for argument 1`,
+		},
+		{
+			name:        "skipped field",
+			expression:  `Secret == ""`,
+			expectError: `1:1: unknown variable "Secret"`,
+		},
+		{
+			name:        "nil nested struct",
+			expression:  `group.name == ""`,
+			expectError: `1:1: unknown variable "group.name"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			expr, err := ParseExpression(test.expression)
+			assert.NoError(err)
+
+			result, err := expr.Evaluate(instance)
+			if test.expectError != "" {
+				assert.EqualError(err, test.expectError)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectResult, result)
+			}
+		})
+	}
+}
+
+func TestInstanceFromStructValue(t *testing.T) {
+	assert := assert.New(t)
+	instance, err := InstanceFromStruct(structOwner{Name: "alice", UID: 1000})
+	assert.NoError(err)
+	assert.Equal(VarMap{"name": "alice", "uid": uint64(1000)}, instance.Vars)
+	assert.Contains(instance.Functions, "IsRoot")
+
+	_, err = InstanceFromStruct("root")
+	assert.EqualError(err, "expecting a struct or a pointer to a struct, got string")
+
+	_, err = InstanceFromStruct((*structOwner)(nil))
+	assert.EqualError(err, "expecting a struct or a pointer to a struct, got *main.structOwner")
+}
+
+type structNode struct {
+	Name   string      `expr:"name"`
+	Parent *structNode `expr:"parent"`
+	Owner  *structOwner
+}
+
+func TestInstanceFromStructCycle(t *testing.T) {
+	assert := assert.New(t)
+	owner := &structOwner{Name: "root"}
+	root := &structNode{Name: "root", Owner: owner}
+	child := &structNode{Name: "child", Parent: root, Owner: owner}
+
+	instance, err := InstanceFromStruct(child)
+	assert.NoError(err)
+	assert.Equal(VarMap{
+		"name":              "child",
+		"parent.name":       "root",
+		"Owner.name":        "root",
+		"Owner.uid":         uint64(0),
+		"parent.Owner.name": "root",
+		"parent.Owner.uid":  uint64(0),
+	}, instance.Vars)
+
+	root.Parent = root
+	_, err = InstanceFromStruct(root)
+	assert.EqualError(err, "cycle of pointers at field parent")
+
+	root.Parent = child
+	_, err = InstanceFromStruct(child)
+	assert.EqualError(err, "cycle of pointers at field parent.parent")
+}
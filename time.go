@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/participle/lexer"
+)
+
+// Duration is a duration literal, such as 30d, 24h, 500ms or 1h30m
+type Duration time.Duration
+
+// Capture parses a duration literal
+func (d *Duration) Capture(values []string) error {
+	duration, err := parseDuration(strings.Join(values, ""))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"ns", time.Nanosecond},
+	{"us", time.Microsecond},
+	{"µs", time.Microsecond},
+	{"ms", time.Millisecond},
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+}
+
+// parseDuration parses a sequence of integers with units, extending time.ParseDuration units with days and weeks
+func parseDuration(s string) (time.Duration, error) {
+	negative := strings.HasPrefix(s, "-")
+	rest := strings.TrimLeft(s, "+-")
+
+	var total time.Duration
+	for rest != "" {
+		var digits string
+		digits, rest = splitDigits(rest)
+		if digits == "" {
+			return 0, errors.New("expecting a number")
+		}
+
+		var unit time.Duration
+		for _, u := range durationUnits {
+			if strings.HasPrefix(rest, u.suffix) {
+				unit, rest = u.unit, rest[len(u.suffix):]
+				break
+			}
+		}
+		if unit == 0 {
+			return 0, fmt.Errorf("missing unit after %s", digits)
+		}
+
+		n, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil || n > int64(math.MaxInt64/unit) || total > math.MaxInt64-time.Duration(n)*unit {
+			return 0, errors.New("duration overflows")
+		}
+		total += time.Duration(n) * unit
+	}
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
+
+// checkDuration reports invalid duration literals at parse time
+func checkDuration(token lexer.Token) (lexer.Token, error) {
+	if _, err := parseDuration(token.Value); err != nil {
+		return token, lexer.ErrorWithTokenf(token, `invalid duration "%s": %s`, token.Value, err)
+	}
+	return token, nil
+}
+
+// TimeFunctions returns functions producing timestamps:
+//   - now() returns the current time according to the clock of the instance
+//   - timestamp(t) parses an RFC 3339 timestamp or converts unix time in seconds
+func TimeFunctions() FunctionMap {
+	return FunctionMap{
+		"now": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 0, 0); err != nil {
+				return nil, err
+			}
+			return instance.Now(), nil
+		},
+		"timestamp": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			switch arg := args[0].(type) {
+			case string:
+				return time.Parse(time.RFC3339, arg)
+			case int64:
+				return time.Unix(arg, 0), nil
+			case uint64:
+				return time.Unix(int64(arg), 0), nil
+			default:
+				return nil, errors.New("expecting a string or an integer for argument 1")
+			}
+		},
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		name           string
+		duration       string
+		expectDuration time.Duration
+		expectError    string
+	}{
+		{
+			name:           "days",
+			duration:       "30d",
+			expectDuration: 30 * 24 * time.Hour,
+		},
+		{
+			name:           "weeks",
+			duration:       "2w",
+			expectDuration: 14 * 24 * time.Hour,
+		},
+		{
+			name:           "milliseconds",
+			duration:       "500ms",
+			expectDuration: 500 * time.Millisecond,
+		},
+		{
+			name:           "microseconds",
+			duration:       "3µs",
+			expectDuration: 3 * time.Microsecond,
+		},
+		{
+			name:           "compound",
+			duration:       "1h30m15s",
+			expectDuration: time.Hour + 30*time.Minute + 15*time.Second,
+		},
+		{
+			name:           "negative",
+			duration:       "-24h",
+			expectDuration: -24 * time.Hour,
+		},
+		{
+			name:        "missing unit",
+			duration:    "1h30",
+			expectError: "missing unit after 30",
+		},
+		{
+			name:        "overflow",
+			duration:    "1000000w",
+			expectError: "duration overflows",
+		},
+		{
+			name:        "sum overflow",
+			duration:    "2562047h2562047h",
+			expectError: "duration overflows",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			d, err := parseDuration(test.duration)
+			if test.expectError != "" {
+				assert.EqualError(err, test.expectError)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectDuration, d)
+			}
+		})
+	}
+}
+
+func TestParseDurationError(t *testing.T) {
+	assert := assert.New(t)
+	expr, err := ParseExpression("x > 1h30")
+
+	assert.Nil(expr)
+	assert.EqualError(err, `1:5: invalid duration "1h30": missing unit after 30`)
+}
+
+func TestEvalTime(t *testing.T) {
+	now := time.Date(2020, time.June, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		return now
+	}
+	functions := TimeFunctions()
+
+	instanceTests{
+		{
+			name:         "duration literal",
+			expression:   `1h30m`,
+			expectResult: 90 * time.Minute,
+		},
+		{
+			name:         "duration comparison",
+			expression:   `24h == 1d`,
+			expectResult: true,
+		},
+		{
+			name:         "duration arithmetic",
+			expression:   `1w - 6d - 12h`,
+			expectResult: 12 * time.Hour,
+		},
+		{
+			name:         "negative duration",
+			expression:   `-(1h + 30m)`,
+			expectResult: -90 * time.Minute,
+		},
+		{
+			name:         "now",
+			expression:   `now()`,
+			functions:    functions,
+			clock:        clock,
+			expectResult: now,
+		},
+		{
+			name:         "timestamp",
+			expression:   `timestamp("2020-05-01T12:00:00Z") + 31d`,
+			functions:    functions,
+			expectResult: now,
+		},
+		{
+			name:         "unix timestamp",
+			expression:   `timestamp(0) < now()`,
+			functions:    functions,
+			clock:        clock,
+			expectResult: true,
+		},
+		{
+			name:       "certificate expires in more than 30 days",
+			expression: `cert.expiry - now() > 30d`,
+			vars: VarMap{
+				"cert.expiry": now.Add(45 * 24 * time.Hour),
+			},
+			functions:    functions,
+			clock:        clock,
+			expectResult: true,
+		},
+		{
+			name:       "log modified within 24h",
+			expression: `file.mtime > now() - 24h`,
+			vars: VarMap{
+				"file.mtime": now.Add(-25 * time.Hour),
+			},
+			functions:    functions,
+			clock:        clock,
+			expectResult: false,
+		},
+		{
+			name:         "subtraction without spaces",
+			expression:   `now()-1h-30m < now()-1h`,
+			functions:    functions,
+			clock:        clock,
+			expectResult: true,
+		},
+		{
+			name:         "chained subtraction",
+			expression:   `now() - 1h - 30m`,
+			functions:    functions,
+			clock:        clock,
+			expectResult: now.Add(-90 * time.Minute),
+		},
+		{
+			name:         "negative duration in array",
+			expression:   `-2h in [1h, -2h]`,
+			expectResult: true,
+		},
+		{
+			name:       "duration plus timestamp",
+			expression: `2h + file.mtime`,
+			vars: VarMap{
+				"file.mtime": now.Add(-2 * time.Hour),
+			},
+			expectResult: now,
+		},
+		{
+			name:        "invalid timestamp comparison",
+			expression:  `now() > 1d`,
+			functions:   functions,
+			expectError: newLexerError(0, "rhs of > must be a timestamp"),
+		},
+		{
+			name:        "invalid duration comparison",
+			expression:  `1d > "1d"`,
+			expectError: newLexerError(0, "rhs of > must be a duration"),
+		},
+		{
+			name:        "invalid timestamp operand",
+			expression:  `now() - 1`,
+			functions:   functions,
+			expectError: newLexerError(0, "rhs of - must be a duration or timestamp"),
+		},
+		{
+			name:        "unsupported timestamp operator",
+			expression:  `now() + now()`,
+			functions:   functions,
+			expectError: newLexerError(0, "unsupported timestamp binary operator +"),
+		},
+		{
+			name:        "unsupported duration operator",
+			expression:  `1d & 1h`,
+			expectError: newLexerError(0, "unsupported duration binary operator &"),
+		},
+		{
+			name:        "invalid timestamp",
+			expression:  `timestamp("yesterday")`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "timestamp()" failed: parsing time "yesterday" as "2006-01-02T15:04:05Z07:00": cannot parse "yesterday" as "2006"`),
+		},
+	}.Run(t)
+}
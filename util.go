@@ -1,8 +1,14 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"net"
 	"reflect"
 	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/alecthomas/participle/lexer"
 )
@@ -18,13 +24,69 @@ func notInArray(value interface{}, array []interface{}) bool {
 func arrayOp(value interface{}, array []interface{}, in bool) bool {
 	for _, rhs := range array {
 		rhs = coerceIntegers(rhs)
-		if reflect.DeepEqual(value, rhs) {
+		if arrayElementMatch(value, rhs) {
 			return in
 		}
 	}
 	return !in
 }
 
+// arrayValue returns a value as an array, where a scalar is an array of one element
+func arrayValue(value interface{}) []interface{} {
+	if array, ok := value.([]interface{}); ok {
+		return array
+	}
+	return []interface{}{value}
+}
+
+// anyOfArray checks if at least one of values is in array
+func anyOfArray(values, array []interface{}) bool {
+	for _, value := range values {
+		if inArray(coerceIntegers(value), array) {
+			return true
+		}
+	}
+	return false
+}
+
+// subsetOfArray checks if all of values are in array
+func subsetOfArray(values, array []interface{}) bool {
+	for _, value := range values {
+		if !inArray(coerceIntegers(value), array) {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayElementMatch checks if a value matches an array element, where IP addresses and their
+// string representations match equal addresses and networks containing them, including
+// networks in CIDR notation
+func arrayElementMatch(value, element interface{}) bool {
+	switch element := element.(type) {
+	case *net.IPNet:
+		ip, ok := ipValue(value)
+		return ok && element.Contains(ip)
+	case net.IP:
+		ip, ok := ipValue(value)
+		return ok && ip.Equal(element)
+	case string:
+		switch value := value.(type) {
+		case net.IP:
+			if _, network, err := net.ParseCIDR(element); err == nil {
+				return network.Contains(value)
+			}
+			return value.Equal(net.ParseIP(element))
+		case string:
+			if _, network, err := net.ParseCIDR(element); err == nil && value != element {
+				ip := net.ParseIP(value)
+				return ip != nil && network.Contains(ip)
+			}
+		}
+	}
+	return reflect.DeepEqual(value, element)
+}
+
 func stringCompare(op string, lhs, rhs string, pos lexer.Position) (bool, error) {
 	switch op {
 	case "==":
@@ -39,6 +101,10 @@ func stringCompare(op string, lhs, rhs string, pos lexer.Position) (bool, error)
 		return lhs <= rhs, nil
 	case ">=":
 		return lhs >= rhs, nil
+	case "==~":
+		return strings.EqualFold(lhs, rhs), nil
+	case "!=~":
+		return !strings.EqualFold(lhs, rhs), nil
 	case "=~", "!~":
 		re, err := regexp.Compile(rhs)
 		if err != nil {
@@ -49,11 +115,159 @@ func stringCompare(op string, lhs, rhs string, pos lexer.Position) (bool, error)
 			return match, nil
 		}
 		return !match, nil
+	case "like", "notlike", "ilike", "notilike", "glob", "notglob", "iglob", "notiglob":
+		kind := strings.TrimPrefix(op, "not")
+		ignoreCase := strings.HasPrefix(kind, "i")
+		var (
+			re  *regexp.Regexp
+			err error
+		)
+		if strings.HasSuffix(kind, "like") {
+			re, err = likeToRegexp(rhs, ignoreCase)
+		} else {
+			re, err = globToRegexp(rhs, ignoreCase)
+		}
+		if err != nil {
+			return false, lexer.Errorf(pos, `failed to parse pattern "%s" for string match using %s`, rhs, op)
+		}
+		return re.MatchString(lhs) != strings.HasPrefix(op, "not"), nil
 	default:
 		return false, lexer.Errorf(pos, "unsupported operator %s for string comparison", op)
 	}
 }
 
+// likeToRegexp converts SQL LIKE pattern to a regular expression, where "%" matches any sequence
+// of characters, "_" matches a single character and backslash escapes the following character
+func likeToRegexp(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		case '\\':
+			if i++; i == len(pattern) {
+				return nil, errors.New("trailing backslash")
+			}
+			i = writeQuotedRune(&b, pattern, i)
+		default:
+			i = writeQuotedRune(&b, pattern, i)
+		}
+	}
+	return compileAnchored(b.String(), ignoreCase)
+}
+
+// writeQuotedRune writes the character of a pattern at offset i quoted for a regular expression,
+// returning the offset of its last byte
+func writeQuotedRune(b *strings.Builder, pattern string, i int) int {
+	_, size := utf8.DecodeRuneInString(pattern[i:])
+	b.WriteString(regexp.QuoteMeta(pattern[i : i+size]))
+	return i + size - 1
+}
+
+// globToRegexp converts shell-style glob pattern to a regular expression, where "*" matches any
+// sequence of characters but "/", "?" matches a single character but "/", "[...]" and "[!...]"
+// match character classes, "{a,b}" matches alternatives and backslash escapes the following
+// character. As with path.Match, negated classes don't match "/" either.
+func globToRegexp(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	alternatives, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var expressions []string
+	for _, alternative := range alternatives {
+		var b strings.Builder
+		for i := 0; i < len(alternative); i++ {
+			switch alternative[i] {
+			case '*':
+				b.WriteString("[^/]*")
+			case '?':
+				b.WriteString("[^/]")
+			case '[':
+				class, end, err := globClassToRegexp(alternative, i+1)
+				if err != nil {
+					return nil, err
+				}
+				b.WriteString(class)
+				i = end
+			case '\\':
+				if i++; i == len(alternative) {
+					return nil, errors.New("trailing backslash")
+				}
+				i = writeQuotedRune(&b, alternative, i)
+			default:
+				i = writeQuotedRune(&b, alternative, i)
+			}
+		}
+		expressions = append(expressions, b.String())
+	}
+	return compileAnchored(strings.Join(expressions, "|"), ignoreCase)
+}
+
+// globClassToRegexp converts a character class of a glob pattern starting at offset to a regular
+// expression, returning the offset of its closing bracket. Members are escaped so that
+// characters special to regular expressions match literally, while "]" is a member when it
+// comes first and backslash escapes the following character.
+func globClassToRegexp(pattern string, offset int) (string, int, error) {
+	var b strings.Builder
+	i := offset
+	negated := i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^')
+	if negated {
+		i++
+	}
+	start := i
+
+	// member returns the character at i, handling escapes, and the offset following it
+	member := func(i int) (rune, int, error) {
+		if pattern[i] == '\\' {
+			if i++; i == len(pattern) {
+				return 0, 0, errors.New("trailing backslash")
+			}
+		}
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		return r, i + size, nil
+	}
+
+	for i < len(pattern) {
+		if pattern[i] == ']' && i > start {
+			class := b.String()
+			if negated {
+				return "[^" + class + "/]", i, nil
+			}
+			return "[" + class + "]", i, nil
+		}
+		lo, next, err := member(i)
+		if err != nil {
+			return "", 0, err
+		}
+		hi := lo
+		if next+1 < len(pattern) && pattern[next] == '-' && pattern[next+1] != ']' {
+			if hi, next, err = member(next + 1); err != nil {
+				return "", 0, err
+			}
+			if hi < lo {
+				return "", 0, errors.New("invalid character range")
+			}
+		}
+		fmt.Fprintf(&b, `\x{%x}`, lo)
+		if hi != lo {
+			fmt.Fprintf(&b, `-\x{%x}`, hi)
+		}
+		i = next
+	}
+	return "", 0, errors.New("unterminated character class")
+}
+
+func compileAnchored(expr string, ignoreCase bool) (*regexp.Regexp, error) {
+	flags := "(?s)"
+	if ignoreCase {
+		flags = "(?is)"
+	}
+	return regexp.Compile(flags + "^(?:" + expr + ")$")
+}
+
 func uintCompare(op string, lhs, rhs uint64, pos lexer.Position) (bool, error) {
 	switch op {
 	case "==":
@@ -92,6 +306,25 @@ func intCompare(op string, lhs, rhs int64, pos lexer.Position) (bool, error) {
 	}
 }
 
+func orderedCompare(op string, result int, pos lexer.Position) (bool, error) {
+	switch op {
+	case "==":
+		return result == 0, nil
+	case "!=":
+		return result != 0, nil
+	case "<":
+		return result < 0, nil
+	case ">":
+		return result > 0, nil
+	case "<=":
+		return result <= 0, nil
+	case ">=":
+		return result >= 0, nil
+	default:
+		return false, lexer.Errorf(pos, "unsupported operator %s for comparison", op)
+	}
+}
+
 func uintBinaryOp(op string, lhs, rhs uint64, pos lexer.Position) (uint64, error) {
 	switch op {
 	case "&":
@@ -107,6 +340,10 @@ func uintBinaryOp(op string, lhs, rhs uint64, pos lexer.Position) (uint64, error
 
 func intBinaryOp(op string, lhs, rhs int64, pos lexer.Position) (int64, error) {
 	switch op {
+	case "+":
+		return lhs + rhs, nil
+	case "-":
+		return lhs - rhs, nil
 	case "&":
 		return lhs & rhs, nil
 	case "|":
@@ -127,6 +364,55 @@ func stringBinaryOp(op string, lhs, rhs string, pos lexer.Position) (string, err
 	}
 }
 
+func timeBinaryOp(op string, lhs time.Time, rhs interface{}, pos lexer.Position) (interface{}, error) {
+	switch rhs := rhs.(type) {
+	case time.Duration:
+		switch op {
+		case "+":
+			return lhs.Add(rhs), nil
+		case "-":
+			return lhs.Add(-rhs), nil
+		}
+	case time.Time:
+		if op == "-" {
+			return lhs.Sub(rhs), nil
+		}
+	default:
+		return nil, lexer.Errorf(pos, "rhs of %s must be a duration or timestamp", op)
+	}
+	return nil, lexer.Errorf(pos, "unsupported timestamp binary operator %s", op)
+}
+
+func durationBinaryOp(op string, lhs time.Duration, rhs interface{}, pos lexer.Position) (interface{}, error) {
+	switch rhs := rhs.(type) {
+	case time.Duration:
+		switch op {
+		case "+":
+			return lhs + rhs, nil
+		case "-":
+			return lhs - rhs, nil
+		}
+	case time.Time:
+		if op == "+" {
+			return rhs.Add(lhs), nil
+		}
+	default:
+		return nil, lexer.Errorf(pos, "rhs of %s must be a duration or timestamp", op)
+	}
+	return nil, lexer.Errorf(pos, "unsupported duration binary operator %s", op)
+}
+
+func compareTimes(lhs, rhs time.Time) int {
+	switch {
+	case lhs.Before(rhs):
+		return -1
+	case lhs.After(rhs):
+		return 1
+	default:
+		return 0
+	}
+}
+
 func coerceIntegers(value interface{}) interface{} {
 	switch value := value.(type) {
 	case int:
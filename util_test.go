@@ -168,6 +168,41 @@ func TestStringCompare(t *testing.T) {
 			right:        "abc",
 			expectResult: false,
 		},
+		{
+			name:         "equal ignoring case true",
+			op:           "==~",
+			left:         "Straße",
+			right:        "STRAßE",
+			expectResult: true,
+		},
+		{
+			name:         "equal ignoring case without full case folding",
+			op:           "==~",
+			left:         "Straße",
+			right:        "STRASSE",
+			expectResult: false,
+		},
+		{
+			name:         "equal ignoring case of kelvin sign",
+			op:           "==~",
+			left:         "\u212a",
+			right:        "k",
+			expectResult: true,
+		},
+		{
+			name:         "not equal ignoring case true",
+			op:           "!=~",
+			left:         "abc",
+			right:        "abd",
+			expectResult: true,
+		},
+		{
+			name:         "not equal ignoring case false",
+			op:           "!=~",
+			left:         "Ǆ",
+			right:        "ǆ",
+			expectResult: false,
+		},
 		{
 			name:         "regexp true",
 			op:           "=~",
@@ -203,6 +238,286 @@ func TestStringCompare(t *testing.T) {
 			right:       "*",
 			expectError: newLexerError(0, `failed to parse regexp "*" for string match using =~`),
 		},
+		{
+			name:         "like true",
+			op:           "like",
+			left:         `/etc/passwd`,
+			right:        `/etc/%`,
+			expectResult: true,
+		},
+		{
+			name:         "like single character",
+			op:           "like",
+			left:         `abc`,
+			right:        `a_c`,
+			expectResult: true,
+		},
+		{
+			name:         "like escaped wildcard false",
+			op:           "like",
+			left:         `abc`,
+			right:        `ab\%`,
+			expectResult: false,
+		},
+		{
+			name:         "like escaped wildcard true",
+			op:           "like",
+			left:         `ab%`,
+			right:        `ab\%`,
+			expectResult: true,
+		},
+		{
+			name:         "like case sensitive",
+			op:           "like",
+			left:         `ABC`,
+			right:        `a%`,
+			expectResult: false,
+		},
+		{
+			name:         "ilike true",
+			op:           "ilike",
+			left:         `ABC`,
+			right:        `a%`,
+			expectResult: true,
+		},
+		{
+			name:         "not like true",
+			op:           "notlike",
+			left:         `abc`,
+			right:        `b%`,
+			expectResult: true,
+		},
+		{
+			name:         "not like false",
+			op:           "notlike",
+			left:         `abc`,
+			right:        `a%`,
+			expectResult: false,
+		},
+		{
+			name:         "like metacharacters",
+			op:           "like",
+			left:         `a.c`,
+			right:        `a.c`,
+			expectResult: true,
+		},
+		{
+			name:         "like metacharacters false",
+			op:           "like",
+			left:         `abc`,
+			right:        `a.c`,
+			expectResult: false,
+		},
+		{
+			name:         "like non-ASCII",
+			op:           "like",
+			left:         `café`,
+			right:        `café`,
+			expectResult: true,
+		},
+		{
+			name:         "like escaped non-ASCII",
+			op:           "like",
+			left:         `café`,
+			right:        `caf\é`,
+			expectResult: true,
+		},
+		{
+			name:         "like single non-ASCII character",
+			op:           "like",
+			left:         `café`,
+			right:        `caf_`,
+			expectResult: true,
+		},
+		{
+			name:         "ilike non-ASCII",
+			op:           "ilike",
+			left:         `Ärger`,
+			right:        `ärger`,
+			expectResult: true,
+		},
+		{
+			name:         "glob non-ASCII",
+			op:           "glob",
+			left:         `/home/josé/x`,
+			right:        `/home/josé/*`,
+			expectResult: true,
+		},
+		{
+			name:         "glob escaped non-ASCII",
+			op:           "glob",
+			left:         `/home/josé`,
+			right:        `/home/jos\é`,
+			expectResult: true,
+		},
+		{
+			name:         "iglob non-ASCII",
+			op:           "iglob",
+			left:         `/HOME/JOSÉ`,
+			right:        `/home/josé`,
+			expectResult: true,
+		},
+		{
+			name:         "glob true",
+			op:           "glob",
+			left:         `/etc/passwd`,
+			right:        `/etc/*`,
+			expectResult: true,
+		},
+		{
+			name:         "glob single character",
+			op:           "glob",
+			left:         `abc`,
+			right:        `a?c`,
+			expectResult: true,
+		},
+		{
+			name:         "glob character class",
+			op:           "glob",
+			left:         `abc`,
+			right:        `a[a-c]c`,
+			expectResult: true,
+		},
+		{
+			name:         "glob negated character class",
+			op:           "glob",
+			left:         `abc`,
+			right:        `a[!b]c`,
+			expectResult: false,
+		},
+		{
+			name:         "glob star stops at slash",
+			op:           "glob",
+			left:         `/etc/ssh/sshd_config`,
+			right:        `/etc/*`,
+			expectResult: false,
+		},
+		{
+			name:         "glob star in segments",
+			op:           "glob",
+			left:         `/etc/ssh/sshd_config`,
+			right:        `/*/*/sshd_*`,
+			expectResult: true,
+		},
+		{
+			name:         "glob single character stops at slash",
+			op:           "glob",
+			left:         `a/c`,
+			right:        `a?c`,
+			expectResult: false,
+		},
+		{
+			name:         "iglob star stops at slash",
+			op:           "iglob",
+			left:         `/ETC/SSH/KEY`,
+			right:        `/etc/*key`,
+			expectResult: false,
+		},
+		{
+			name:         "glob negated character class excludes slash",
+			op:           "glob",
+			left:         `a/c`,
+			right:        `a[!b]c`,
+			expectResult: false,
+		},
+		{
+			name:         "glob character class with metacharacters",
+			op:           "glob",
+			left:         `a.c`,
+			right:        `a[.^]c`,
+			expectResult: true,
+		},
+		{
+			name:         "glob character class with metacharacters false",
+			op:           "glob",
+			left:         `abc`,
+			right:        `a[.^]c`,
+			expectResult: false,
+		},
+		{
+			name:         "glob character class with escaped and leading brackets",
+			op:           "glob",
+			left:         `a]c`,
+			right:        `a[]\[]c`,
+			expectResult: true,
+		},
+		{
+			name:         "glob character class with trailing dash",
+			op:           "glob",
+			left:         `a-c`,
+			right:        `a[x-]c`,
+			expectResult: true,
+		},
+		{
+			name:         "glob negated character class with caret",
+			op:           "glob",
+			left:         `abc`,
+			right:        `a[^b]c`,
+			expectResult: false,
+		},
+		{
+			name:         "glob unicode character range",
+			op:           "glob",
+			left:         `café`,
+			right:        `caf[à-ÿ]`,
+			expectResult: true,
+		},
+		{
+			name:        "glob invalid character range",
+			op:          "glob",
+			left:        "abc",
+			right:       "a[c-a]c",
+			expectError: newLexerError(0, `failed to parse pattern "a[c-a]c" for string match using glob`),
+		},
+		{
+			name:         "glob alternation",
+			op:           "glob",
+			left:         `config.yml`,
+			right:        `*.{yaml,yml}`,
+			expectResult: true,
+		},
+		{
+			name:         "glob case sensitive",
+			op:           "glob",
+			left:         `ABC`,
+			right:        `a*`,
+			expectResult: false,
+		},
+		{
+			name:         "iglob true",
+			op:           "iglob",
+			left:         `ABC`,
+			right:        `a*`,
+			expectResult: true,
+		},
+		{
+			name:         "not glob true",
+			op:           "notglob",
+			left:         `abc`,
+			right:        `b*`,
+			expectResult: true,
+		},
+		{
+			name:         "not iglob false",
+			op:           "notiglob",
+			left:         `ABC`,
+			right:        `a*`,
+			expectResult: false,
+		},
+		{
+			name:        "glob invalid",
+			op:          "glob",
+			left:        "abc",
+			right:       "a[bc",
+			expectError: newLexerError(0, `failed to parse pattern "a[bc" for string match using glob`),
+		},
+		{
+			name:        "like invalid",
+			op:          "like",
+			left:        "abc",
+			right:       `abc\`,
+			expectError: newLexerError(0, `failed to parse pattern "abc\" for string match using like`),
+		},
 		{
 			name:        "unsupported operator",
 			op:          "<>",
@@ -235,6 +550,72 @@ func TestIntCompare(t *testing.T) {
 
 }
 
+func TestOrderedCompare(t *testing.T) {
+	tests := []struct {
+		name         string
+		op           string
+		result       int
+		expectResult bool
+		expectError  error
+	}{
+		{
+			name:         "equal",
+			op:           "==",
+			result:       0,
+			expectResult: true,
+		},
+		{
+			name:         "not equal",
+			op:           "!=",
+			result:       1,
+			expectResult: true,
+		},
+		{
+			name:         "less",
+			op:           "<",
+			result:       1,
+			expectResult: false,
+		},
+		{
+			name:         "greater",
+			op:           ">",
+			result:       1,
+			expectResult: true,
+		},
+		{
+			name:         "less or equal",
+			op:           "<=",
+			result:       0,
+			expectResult: true,
+		},
+		{
+			name:         "greater or equal",
+			op:           ">=",
+			result:       -1,
+			expectResult: false,
+		},
+		{
+			name:        "unsupported operator",
+			op:          "=~",
+			expectError: newLexerError(0, "unsupported operator =~ for comparison"),
+		},
+	}
+	pos := lexer.Position{Offset: 0, Column: 1, Line: 1}
+	assert := assert.New(t)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := orderedCompare(test.op, test.result, pos)
+			if test.expectError != nil {
+				assert.Equal(test.expectError, err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectResult, actual)
+			}
+		})
+	}
+}
+
 func TestUintBinaryOp(t *testing.T) {
 	tests := []struct {
 		name         string
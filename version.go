@@ -0,0 +1,444 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VersionFunctions returns functions producing version values comparable with scalar comparison operators:
+//   - semver(s) parses a semantic version, such as "1.2.3-rc.1+build", where minor and patch may be omitted
+//   - version(s) parses a Debian package version in "[epoch:]upstream[-revision]" form ordered as by dpkg
+//   - rpmversion(s) parses an RPM package version in "[epoch:]version[-release]" form ordered as by rpm
+//
+// Versions compare with other versions of the same kind or strings parsed accordingly,
+// e.g. semver(kernel) > "5.9".
+func VersionFunctions() FunctionMap {
+	return FunctionMap{
+		"semver": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			s, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return ParseSemanticVersion(s)
+		},
+		"version": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			s, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return ParsePackageVersion(s)
+		},
+		"rpmversion": func(instance *Instance, args ...interface{}) (interface{}, error) {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			s, err := stringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return ParseRPMVersion(s)
+		},
+	}
+}
+
+// SemanticVersion is a version ordered according to semantic versioning 2.0 precedence rules
+type SemanticVersion struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      string
+}
+
+// ParseSemanticVersion parses a semantic version allowing for an optional "v" prefix and omitted minor or patch
+func ParseSemanticVersion(s string) (*SemanticVersion, error) {
+	v := &SemanticVersion{}
+
+	rest := strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		rest, v.Build = rest[:i], rest[i+1:]
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		var prerelease string
+		rest, prerelease = rest[:i], rest[i+1:]
+		v.Prerelease = strings.Split(prerelease, ".")
+		for _, identifier := range v.Prerelease {
+			if identifier == "" {
+				return nil, fmt.Errorf(`invalid semantic version "%s"`, s)
+			}
+		}
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf(`invalid semantic version "%s"`, s)
+	}
+	numbers := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid semantic version "%s"`, s)
+		}
+		*numbers[i] = n
+	}
+	return v, nil
+}
+
+func (v *SemanticVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare compares a semantic version to another semantic version or a string, ignoring build metadata
+func (v *SemanticVersion) Compare(other interface{}) (int, error) {
+	var rhs *SemanticVersion
+	switch other := other.(type) {
+	case *SemanticVersion:
+		rhs = other
+	case string:
+		var err error
+		if rhs, err = ParseSemanticVersion(other); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, errors.New("expecting a semantic version")
+	}
+
+	for _, pair := range [][2]uint64{{v.Major, rhs.Major}, {v.Minor, rhs.Minor}, {v.Patch, rhs.Patch}} {
+		if pair[0] != pair[1] {
+			return compareUints(pair[0], pair[1]), nil
+		}
+	}
+
+	// A version without pre-release identifiers has higher precedence
+	switch {
+	case len(v.Prerelease) == 0 && len(rhs.Prerelease) == 0:
+		return 0, nil
+	case len(v.Prerelease) == 0:
+		return 1, nil
+	case len(rhs.Prerelease) == 0:
+		return -1, nil
+	}
+
+	for i := 0; i < len(v.Prerelease) && i < len(rhs.Prerelease); i++ {
+		if result := comparePrerelease(v.Prerelease[i], rhs.Prerelease[i]); result != 0 {
+			return result, nil
+		}
+	}
+	return compareUints(uint64(len(v.Prerelease)), uint64(len(rhs.Prerelease))), nil
+}
+
+// comparePrerelease compares pre-release identifiers, numeric identifiers having lower precedence
+func comparePrerelease(lhs, rhs string) int {
+	l, lerr := strconv.ParseUint(lhs, 10, 64)
+	r, rerr := strconv.ParseUint(rhs, 10, 64)
+	switch {
+	case lerr == nil && rerr == nil:
+		return compareUints(l, r)
+	case lerr == nil:
+		return -1
+	case rerr == nil:
+		return 1
+	default:
+		return strings.Compare(lhs, rhs)
+	}
+}
+
+// PackageVersion is a version of a Debian package ordered using the dpkg algorithm
+type PackageVersion struct {
+	Epoch    uint64
+	Upstream string
+	Revision string
+}
+
+// ParsePackageVersion parses a package version in "[epoch:]upstream[-revision]" form
+func ParsePackageVersion(s string) (*PackageVersion, error) {
+	v := &PackageVersion{}
+
+	rest := s
+	if i := strings.IndexByte(rest, ':'); i >= 0 {
+		epoch, err := strconv.ParseUint(rest[:i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid epoch in package version "%s"`, s)
+		}
+		v.Epoch, rest = epoch, rest[i+1:]
+	}
+	if i := strings.LastIndexByte(rest, '-'); i >= 0 {
+		rest, v.Revision = rest[:i], rest[i+1:]
+	}
+	if rest == "" || rest[0] < '0' || rest[0] > '9' {
+		return nil, fmt.Errorf(`invalid package version "%s"`, s)
+	}
+	v.Upstream = rest
+	return v, nil
+}
+
+func (v *PackageVersion) String() string {
+	s := v.Upstream
+	if v.Epoch != 0 {
+		s = strconv.FormatUint(v.Epoch, 10) + ":" + s
+	}
+	if v.Revision != "" {
+		s += "-" + v.Revision
+	}
+	return s
+}
+
+// Compare compares a package version to another package version or a string
+func (v *PackageVersion) Compare(other interface{}) (int, error) {
+	var rhs *PackageVersion
+	switch other := other.(type) {
+	case *PackageVersion:
+		rhs = other
+	case string:
+		var err error
+		if rhs, err = ParsePackageVersion(other); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, errors.New("expecting a package version")
+	}
+
+	if v.Epoch != rhs.Epoch {
+		return compareUints(v.Epoch, rhs.Epoch), nil
+	}
+	if result := comparePackageVersionPart(v.Upstream, rhs.Upstream); result != 0 {
+		return result, nil
+	}
+	return comparePackageVersionPart(v.Revision, rhs.Revision), nil
+}
+
+// comparePackageVersionPart compares upstream versions or revisions using alternating
+// non-digit and digit segments, where "~" sorts before anything, even the end of a part
+func comparePackageVersionPart(lhs, rhs string) int {
+	for lhs != "" || rhs != "" {
+		for (lhs != "" && !isDigit(lhs[0])) || (rhs != "" && !isDigit(rhs[0])) {
+			l, r := packageVersionOrder(lhs), packageVersionOrder(rhs)
+			if l != r {
+				return compareInts(l, r)
+			}
+			lhs, rhs = lhs[1:], rhs[1:]
+		}
+
+		var l, r string
+		l, lhs = splitDigits(lhs)
+		r, rhs = splitDigits(rhs)
+		l, r = strings.TrimLeft(l, "0"), strings.TrimLeft(r, "0")
+		if len(l) != len(r) {
+			return compareInts(len(l), len(r))
+		}
+		if result := strings.Compare(l, r); result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// packageVersionOrder returns the sort weight of the first character of a non-digit segment
+func packageVersionOrder(s string) int {
+	switch {
+	case s == "" || isDigit(s[0]):
+		return 0
+	case s[0] == '~':
+		return -1
+	case s[0] >= 'a' && s[0] <= 'z', s[0] >= 'A' && s[0] <= 'Z':
+		return int(s[0])
+	default:
+		return int(s[0]) + 256
+	}
+}
+
+// RPMVersion is a version of an RPM package ordered using the rpmvercmp algorithm
+type RPMVersion struct {
+	Epoch   uint64
+	Version string
+	Release string
+}
+
+// ParseRPMVersion parses an RPM package version in "[epoch:]version[-release]" form
+func ParseRPMVersion(s string) (*RPMVersion, error) {
+	v := &RPMVersion{}
+
+	rest := s
+	if i := strings.IndexByte(rest, ':'); i >= 0 {
+		epoch, err := strconv.ParseUint(rest[:i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid epoch in RPM version "%s"`, s)
+		}
+		v.Epoch, rest = epoch, rest[i+1:]
+	}
+	if i := strings.LastIndexByte(rest, '-'); i >= 0 {
+		rest, v.Release = rest[:i], rest[i+1:]
+	}
+	if rest == "" {
+		return nil, fmt.Errorf(`invalid RPM version "%s"`, s)
+	}
+	v.Version = rest
+	return v, nil
+}
+
+func (v *RPMVersion) String() string {
+	s := v.Version
+	if v.Epoch != 0 {
+		s = strconv.FormatUint(v.Epoch, 10) + ":" + s
+	}
+	if v.Release != "" {
+		s += "-" + v.Release
+	}
+	return s
+}
+
+// Compare compares an RPM version to another RPM version or a string
+func (v *RPMVersion) Compare(other interface{}) (int, error) {
+	var rhs *RPMVersion
+	switch other := other.(type) {
+	case *RPMVersion:
+		rhs = other
+	case string:
+		var err error
+		if rhs, err = ParseRPMVersion(other); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, errors.New("expecting an RPM version")
+	}
+
+	if v.Epoch != rhs.Epoch {
+		return compareUints(v.Epoch, rhs.Epoch), nil
+	}
+	if result := rpmvercmp(v.Version, rhs.Version); result != 0 {
+		return result, nil
+	}
+	return rpmvercmp(v.Release, rhs.Release), nil
+}
+
+// rpmvercmp compares versions or releases as rpm does, using alphabetic and numeric segments
+// separated by other characters, where a numeric segment is newer than an alphabetic one,
+// "~" sorts before anything, even the end of a version, and "^" sorts after the end of a
+// version but before anything else
+func rpmvercmp(lhs, rhs string) int {
+	if lhs == rhs {
+		return 0
+	}
+
+	for lhs != "" || rhs != "" {
+		lhs, rhs = strings.TrimLeftFunc(lhs, isRPMSeparator), strings.TrimLeftFunc(rhs, isRPMSeparator)
+
+		switch {
+		case strings.HasPrefix(lhs, "~") || strings.HasPrefix(rhs, "~"):
+			if !strings.HasPrefix(lhs, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(rhs, "~") {
+				return -1
+			}
+			lhs, rhs = lhs[1:], rhs[1:]
+			continue
+		case strings.HasPrefix(lhs, "^") || strings.HasPrefix(rhs, "^"):
+			switch {
+			case lhs == "":
+				return -1
+			case rhs == "":
+				return 1
+			case !strings.HasPrefix(lhs, "^"):
+				return 1
+			case !strings.HasPrefix(rhs, "^"):
+				return -1
+			}
+			lhs, rhs = lhs[1:], rhs[1:]
+			continue
+		case lhs == "" || rhs == "":
+			// The version with segments left is newer
+			return compareInts(len(lhs), len(rhs))
+		}
+
+		var l, r string
+		numeric := isDigit(lhs[0])
+		if numeric {
+			l, lhs = splitDigits(lhs)
+			r, rhs = splitDigits(rhs)
+		} else {
+			l, lhs = splitLetters(lhs)
+			r, rhs = splitLetters(rhs)
+		}
+		if r == "" {
+			// Segments of different types, numeric ones are newer
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		if numeric {
+			l, r = strings.TrimLeft(l, "0"), strings.TrimLeft(r, "0")
+			if len(l) != len(r) {
+				return compareInts(len(l), len(r))
+			}
+		}
+		if result := strings.Compare(l, r); result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// isRPMSeparator reports whether a character separates segments of an RPM version
+func isRPMSeparator(r rune) bool {
+	return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '~' || r == '^')
+}
+
+func splitLetters(s string) (string, string) {
+	i := 0
+	for i < len(s) && (s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z') {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func splitDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func compareUints(lhs, rhs uint64) int {
+	switch {
+	case lhs < rhs:
+		return -1
+	case lhs > rhs:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareInts(lhs, rhs int) int {
+	switch {
+	case lhs < rhs:
+		return -1
+	case lhs > rhs:
+		return 1
+	default:
+		return 0
+	}
+}
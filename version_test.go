@@ -0,0 +1,341 @@
+package main
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestParseSemanticVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		version       string
+		expectVersion *SemanticVersion
+		expectError   string
+	}{
+		{
+			name:          "full",
+			version:       "1.2.3-rc.1+build.5",
+			expectVersion: &SemanticVersion{Major: 1, Minor: 2, Patch: 3, Prerelease: []string{"rc", "1"}, Build: "build.5"},
+		},
+		{
+			name:          "prefix",
+			version:       "v1.19.4",
+			expectVersion: &SemanticVersion{Major: 1, Minor: 19, Patch: 4},
+		},
+		{
+			name:          "major and minor",
+			version:       "5.10",
+			expectVersion: &SemanticVersion{Major: 5, Minor: 10},
+		},
+		{
+			name:        "too many parts",
+			version:     "1.2.3.4",
+			expectError: `invalid semantic version "1.2.3.4"`,
+		},
+		{
+			name:        "not a number",
+			version:     "1.x",
+			expectError: `invalid semantic version "1.x"`,
+		},
+		{
+			name:        "empty pre-release identifier",
+			version:     "1.0.0-rc..1",
+			expectError: `invalid semantic version "1.0.0-rc..1"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			v, err := ParseSemanticVersion(test.version)
+			if test.expectError != "" {
+				assert.EqualError(err, test.expectError)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectVersion, v)
+			}
+		})
+	}
+}
+
+func TestSemanticVersionCompare(t *testing.T) {
+	// Ordered by precedence as in the semantic versioning specification
+	versions := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	assert := assert.New(t)
+	for i := range versions {
+		for j := range versions {
+			lhs, err := ParseSemanticVersion(versions[i])
+			assert.NoError(err)
+			result, err := lhs.Compare(versions[j])
+			assert.NoError(err)
+			assert.Equal(compareInts(i, j), result, "comparing %s to %s", versions[i], versions[j])
+		}
+	}
+
+	v, err := ParseSemanticVersion("1.0.0+build.1")
+	assert.NoError(err)
+	result, err := v.Compare("1.0.0+build.2")
+	assert.NoError(err)
+	assert.Equal(0, result)
+}
+
+func TestParsePackageVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		version       string
+		expectVersion *PackageVersion
+		expectError   string
+	}{
+		{
+			name:          "full",
+			version:       "1:2.30-0ubuntu1.1",
+			expectVersion: &PackageVersion{Epoch: 1, Upstream: "2.30", Revision: "0ubuntu1.1"},
+		},
+		{
+			name:          "hyphen in upstream",
+			version:       "5.4.0-1029-aws",
+			expectVersion: &PackageVersion{Upstream: "5.4.0-1029", Revision: "aws"},
+		},
+		{
+			name:          "upstream only",
+			version:       "7.4p1",
+			expectVersion: &PackageVersion{Upstream: "7.4p1"},
+		},
+		{
+			name:        "invalid epoch",
+			version:     "a:1.0",
+			expectError: `invalid epoch in package version "a:1.0"`,
+		},
+		{
+			name:        "invalid upstream",
+			version:     "1:rc1",
+			expectError: `invalid package version "1:rc1"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			v, err := ParsePackageVersion(test.version)
+			if test.expectError != "" {
+				assert.EqualError(err, test.expectError)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectVersion, v)
+				assert.Equal(test.version, v.String())
+			}
+		})
+	}
+}
+
+func TestPackageVersionCompare(t *testing.T) {
+	// Ordered according to dpkg rules
+	versions := []string{
+		"1.0~rc1",
+		"1.0",
+		"1.0-1",
+		"1.0-1ubuntu1",
+		"1.0-2",
+		"1.0a",
+		"1.0+dfsg",
+		"1.00.1",
+		"1.2",
+		"1.10",
+		"2.0~beta",
+		"2.0",
+		"1:0.9",
+	}
+	assert := assert.New(t)
+	for i := range versions {
+		for j := range versions {
+			lhs, err := ParsePackageVersion(versions[i])
+			assert.NoError(err)
+			result, err := lhs.Compare(versions[j])
+			assert.NoError(err)
+			assert.Equal(compareInts(i, j), result, "comparing %s to %s", versions[i], versions[j])
+		}
+	}
+}
+
+func TestParseRPMVersion(t *testing.T) {
+	tests := []struct {
+		name          string
+		version       string
+		expectVersion *RPMVersion
+		expectError   string
+	}{
+		{
+			name:          "epoch and release",
+			version:       "1:1.1.1k-7.el8_6",
+			expectVersion: &RPMVersion{Epoch: 1, Version: "1.1.1k", Release: "7.el8_6"},
+		},
+		{
+			name:          "version only",
+			version:       "2.0^20200101git1234",
+			expectVersion: &RPMVersion{Version: "2.0^20200101git1234"},
+		},
+		{
+			name:        "invalid epoch",
+			version:     "a:1.0",
+			expectError: `invalid epoch in RPM version "a:1.0"`,
+		},
+		{
+			name:        "missing version",
+			version:     "1:-1",
+			expectError: `invalid RPM version "1:-1"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			v, err := ParseRPMVersion(test.version)
+			if test.expectError != "" {
+				assert.EqualError(err, test.expectError)
+			} else {
+				assert.NoError(err)
+				assert.Equal(test.expectVersion, v)
+				assert.Equal(test.version, v.String())
+			}
+		})
+	}
+}
+
+func TestRPMVersionCompare(t *testing.T) {
+	// Ordered according to rpmvercmp rules
+	versions := []string{
+		"1.0~rc1",
+		"1.0",
+		"1.0-1",
+		"1.0-2",
+		"1.0^git1",
+		"1.0^git1^1",
+		"1.0a",
+		"1.0.1",
+		"1.2",
+		"1.10",
+		"1.10a",
+		"2.0~beta",
+		"2.0",
+		"1:0.9",
+	}
+	assert := assert.New(t)
+	for i := range versions {
+		for j := range versions {
+			lhs, err := ParseRPMVersion(versions[i])
+			assert.NoError(err)
+			result, err := lhs.Compare(versions[j])
+			assert.NoError(err)
+			assert.Equal(compareInts(i, j), result, "comparing %s to %s", versions[i], versions[j])
+		}
+	}
+
+	// Separators and leading zeros are not significant
+	for _, pair := range [][2]string{{"1.01", "1.1"}, {"1_0", "1.0"}, {"1.0.", "1.0"}} {
+		lhs, err := ParseRPMVersion(pair[0])
+		assert.NoError(err)
+		result, err := lhs.Compare(pair[1])
+		assert.NoError(err)
+		assert.Equal(0, result, "comparing %s to %s", pair[0], pair[1])
+	}
+}
+
+func TestEvalVersion(t *testing.T) {
+	functions := VersionFunctions()
+	instanceTests{
+		{
+			name:         "string comparison",
+			expression:   `"5.10" > "5.9"`,
+			expectResult: false,
+		},
+		{
+			name:         "semver comparison",
+			expression:   `semver("5.10") > "5.9"`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "semver rhs",
+			expression:   `"1.19.4" < semver(version)`,
+			vars:         VarMap{"version": "v1.20.0-rc.1"},
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "semver equality",
+			expression:   `semver("1.2.3+a") == semver("1.2.3+b")`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "package version",
+			expression:   `version(openssl) >= "1.1.1f-1ubuntu2.16"`,
+			vars:         VarMap{"openssl": "1.1.1f-1ubuntu2.19"},
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "package version epoch",
+			expression:   `version("1:1.0") > version("9.9")`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "rpm version",
+			expression:   `rpmversion(openssl) > "1:1.1.1k-6.el8_5" && rpmversion("1.0^git1") > "1.0"`,
+			vars:         VarMap{"openssl": "1:1.1.1k-7.el8_6"},
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:         "rpm and dpkg ordering",
+			expression:   `rpmversion("1.0_1") == "1.0.1" && version("1.0_1") > "1.0.1"`,
+			functions:    functions,
+			expectResult: true,
+		},
+		{
+			name:        "invalid rhs",
+			expression:  `semver("1.0.0") > 1`,
+			functions:   functions,
+			expectError: newLexerError(0, "invalid rhs of >: expecting a semantic version"),
+		},
+		{
+			name:        "mixed versions",
+			expression:  `semver("1.0.0") == version("1.0.0")`,
+			functions:   functions,
+			expectError: newLexerError(0, "invalid rhs of ==: expecting a semantic version"),
+		},
+		{
+			name:        "invalid string rhs",
+			expression:  `semver("1.0.0") < "latest"`,
+			functions:   functions,
+			expectError: newLexerError(0, `invalid rhs of <: invalid semantic version "latest"`),
+		},
+		{
+			name:        "unsupported operator",
+			expression:  `semver("1.0.0") =~ "1.0.0"`,
+			functions:   functions,
+			expectError: newLexerError(0, "unsupported operator =~ for comparison"),
+		},
+		{
+			name:        "invalid version",
+			expression:  `semver("x")`,
+			functions:   functions,
+			expectError: newLexerError(0, `call to "semver()" failed: invalid semantic version "x"`),
+		},
+	}.Run(t)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/alecthomas/participle/lexer"
+)
+
+// Visitor visits nodes of a syntax tree walked with Walk
+type Visitor interface {
+	// Visit is invoked for a node before its children, which are walked with the returned
+	// visitor unless it is nil. Visit is then invoked with a nil node after the children.
+	Visit(node Node) Visitor
+}
+
+// Walk traverses a syntax tree in depth-first order, visiting children in source order
+func Walk(node Node, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *IterableExpression:
+		if n.IterableComparison != nil {
+			Walk(n.IterableComparison, v)
+		}
+		if n.Expression != nil {
+			Walk(n.Expression, v)
+		}
+	case *IterableComparison:
+		Walk(n.Expression, v)
+		if n.ScalarComparison != nil {
+			Walk(n.ScalarComparison, v)
+		}
+	case *PathExpression:
+		if n.Expression != nil {
+			Walk(n.Expression, v)
+		}
+	case *Expression:
+		if n.Let != nil {
+			Walk(n.Let, v)
+		}
+		if n.Comparison != nil {
+			Walk(n.Comparison, v)
+		}
+		if n.Next != nil {
+			Walk(n.Next, v)
+		}
+	case *Let:
+		for _, binding := range n.Bindings {
+			Walk(binding, v)
+		}
+		Walk(n.Body, v)
+	case *Binding:
+		Walk(n.Value, v)
+	case *Comparison:
+		for i := range n.Values {
+			Walk(&n.Values[i], v)
+		}
+		if n.Term != nil {
+			Walk(n.Term, v)
+		}
+		if n.ScalarComparison != nil {
+			Walk(n.ScalarComparison, v)
+		}
+		if n.ArrayComparison != nil {
+			Walk(n.ArrayComparison, v)
+		}
+	case *ScalarComparison:
+		Walk(n.Next, v)
+	case *ArrayComparison:
+		Walk(n.Array, v)
+	case *Term:
+		Walk(n.Unary, v)
+		if n.Next != nil {
+			Walk(n.Next, v)
+		}
+	case *Unary:
+		if n.Unary != nil {
+			Walk(n.Unary, v)
+		}
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *Array:
+		for i := range n.Values {
+			Walk(&n.Values[i], v)
+		}
+		if n.Call != nil {
+			Walk(n.Call, v)
+		}
+	case *Value:
+		if n.Call != nil {
+			Walk(n.Call, v)
+		}
+		if n.Subexpression != nil {
+			Walk(n.Subexpression, v)
+		}
+	case *Call:
+		for _, arg := range n.Args {
+			Walk(arg, v)
+		}
+	default:
+		panic(fmt.Sprintf("unexpected node type %T", node))
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if node != nil && f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order calling f for each node,
+// skipping children of a node when f returns false
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
+
+// Rewrite traverses a syntax tree in depth-first order replacing each node with the result of f,
+// which is called after the children of the node have been rewritten. The tree is modified in
+// place and the resulting root is returned. Rewrite panics when f replaces a node with a node of
+// a different type.
+func Rewrite(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case *IterableExpression:
+		if n.IterableComparison != nil {
+			n.IterableComparison = rewriteAs(n.IterableComparison, f).(*IterableComparison)
+		}
+		if n.Expression != nil {
+			n.Expression = rewriteAs(n.Expression, f).(*Expression)
+		}
+	case *IterableComparison:
+		n.Expression = rewriteAs(n.Expression, f).(*Expression)
+		if n.ScalarComparison != nil {
+			n.ScalarComparison = rewriteAs(n.ScalarComparison, f).(*ScalarComparison)
+		}
+	case *PathExpression:
+		if n.Expression != nil {
+			n.Expression = rewriteAs(n.Expression, f).(*Expression)
+		}
+	case *Expression:
+		if n.Let != nil {
+			n.Let = rewriteAs(n.Let, f).(*Let)
+		}
+		if n.Comparison != nil {
+			n.Comparison = rewriteAs(n.Comparison, f).(*Comparison)
+		}
+		if n.Next != nil {
+			n.Next = rewriteAs(n.Next, f).(*Expression)
+		}
+	case *Let:
+		for i, binding := range n.Bindings {
+			n.Bindings[i] = rewriteAs(binding, f).(*Binding)
+		}
+		n.Body = rewriteAs(n.Body, f).(*Expression)
+	case *Binding:
+		n.Value = rewriteAs(n.Value, f).(*Term)
+	case *Comparison:
+		for i := range n.Values {
+			n.Values[i] = *rewriteAs(&n.Values[i], f).(*Value)
+		}
+		if n.Term != nil {
+			n.Term = rewriteAs(n.Term, f).(*Term)
+		}
+		if n.ScalarComparison != nil {
+			n.ScalarComparison = rewriteAs(n.ScalarComparison, f).(*ScalarComparison)
+		}
+		if n.ArrayComparison != nil {
+			n.ArrayComparison = rewriteAs(n.ArrayComparison, f).(*ArrayComparison)
+		}
+	case *ScalarComparison:
+		n.Next = rewriteAs(n.Next, f).(*Comparison)
+	case *ArrayComparison:
+		n.Array = rewriteAs(n.Array, f).(*Array)
+	case *Term:
+		n.Unary = rewriteAs(n.Unary, f).(*Unary)
+		if n.Next != nil {
+			n.Next = rewriteAs(n.Next, f).(*Term)
+		}
+	case *Unary:
+		if n.Unary != nil {
+			n.Unary = rewriteAs(n.Unary, f).(*Unary)
+		}
+		if n.Value != nil {
+			n.Value = rewriteAs(n.Value, f).(*Value)
+		}
+	case *Array:
+		for i := range n.Values {
+			n.Values[i] = *rewriteAs(&n.Values[i], f).(*Value)
+		}
+		if n.Call != nil {
+			n.Call = rewriteAs(n.Call, f).(*Call)
+		}
+	case *Value:
+		if n.Call != nil {
+			n.Call = rewriteAs(n.Call, f).(*Call)
+		}
+		if n.Subexpression != nil {
+			n.Subexpression = rewriteAs(n.Subexpression, f).(*Expression)
+		}
+	case *Call:
+		for i, arg := range n.Args {
+			n.Args[i] = rewriteAs(arg, f).(*Expression)
+		}
+	default:
+		panic(fmt.Sprintf("unexpected node type %T", node))
+	}
+	return f(node)
+}
+
+// rewriteAs rewrites a node checking that the result has the same type
+func rewriteAs(node Node, f func(Node) Node) Node {
+	result := Rewrite(node, f)
+	if reflect.TypeOf(result) != reflect.TypeOf(node) {
+		panic(fmt.Sprintf("cannot replace %T with %T", node, result))
+	}
+	return result
+}
+
+// Position implementations of syntax tree nodes
+
+func (e *IterableExpression) Position() lexer.Position { return e.Pos }
+func (c *IterableComparison) Position() lexer.Position { return c.Pos }
+func (e *PathExpression) Position() lexer.Position     { return e.Pos }
+func (e *Expression) Position() lexer.Position         { return e.Pos }
+func (l *Let) Position() lexer.Position                { return l.Pos }
+func (b *Binding) Position() lexer.Position            { return b.Pos }
+func (c *Comparison) Position() lexer.Position         { return c.Pos }
+func (c *ScalarComparison) Position() lexer.Position   { return c.Pos }
+func (c *ArrayComparison) Position() lexer.Position    { return c.Pos }
+func (t *Term) Position() lexer.Position               { return t.Pos }
+func (u *Unary) Position() lexer.Position              { return u.Pos }
+func (a *Array) Position() lexer.Position              { return a.Pos }
+func (v *Value) Position() lexer.Position              { return v.Pos }
+func (c *Call) Position() lexer.Position               { return c.Pos }
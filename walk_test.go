@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+type recordingVisitor struct {
+	nodes []string
+	skip  string
+}
+
+func (v *recordingVisitor) Visit(node Node) Visitor {
+	if node == nil {
+		v.nodes = append(v.nodes, "end")
+		return nil
+	}
+	name := strings.TrimPrefix(fmt.Sprintf("%T", node), "*main.")
+	v.nodes = append(v.nodes, fmt.Sprintf("%s@%d", name, node.Position().Column))
+	if name == v.skip {
+		return nil
+	}
+	return v
+}
+
+func TestWalk(t *testing.T) {
+	assert := assert.New(t)
+	expr, err := ParseExpression(`f(x) in [1]`)
+	assert.NoError(err)
+
+	v := &recordingVisitor{}
+	Walk(expr, v)
+	assert.Equal([]string{
+		"Expression@1",
+		"Comparison@1",
+		"Term@1",
+		"Unary@1",
+		"Value@1",
+		"Call@1",
+		"Expression@3",
+		"Comparison@3",
+		"Term@3",
+		"Unary@3",
+		"Value@3",
+		"end", "end", "end", "end", "end", "end", "end", "end", "end",
+		"ArrayComparison@6",
+		"Array@9",
+		"Value@10",
+		"end", "end", "end", "end", "end",
+	}, v.nodes)
+
+	v = &recordingVisitor{skip: "Comparison"}
+	Walk(expr, v)
+	assert.Equal([]string{"Expression@1", "Comparison@1", "end"}, v.nodes)
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name       string
+		parse      func(string) (Node, error)
+		expression string
+		expected   []string
+	}{
+		{
+			name:       "expression",
+			parse:      func(s string) (Node, error) { return ParseExpression(s) },
+			expression: `a == b && !(c in d) && e all of f(g, -h) && i =~ j(k)`,
+			expected:   []string{"a", "b", "c", "d", "e", "f()", "g", "h", "i", "j()", "k"},
+		},
+		{
+			name:       "iterable",
+			parse:      func(s string) (Node, error) { return ParseIterable(s) },
+			expression: `count(a == b) > c`,
+			expected:   []string{"a", "b", "c"},
+		},
+		{
+			name:       "path",
+			parse:      func(s string) (Node, error) { return ParsePath(s) },
+			expression: `"/etc/" + a`,
+			expected:   []string{"a"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+			node, err := test.parse(test.expression)
+			assert.NoError(err)
+
+			var names []string
+			Inspect(node, func(node Node) bool {
+				switch node := node.(type) {
+				case *Value:
+					if node.Variable != nil {
+						names = append(names, *node.Variable)
+					}
+				case *Array:
+					if node.Ident != nil {
+						names = append(names, *node.Ident)
+					}
+				case *Call:
+					names = append(names, node.Name+"()")
+				}
+				return true
+			})
+			assert.Equal(test.expected, names)
+		})
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	assert := assert.New(t)
+	expr, err := ParseExpression(`lowercase(a) == "x" && b in lowercase(c) && d in [e, "y"]`)
+	assert.NoError(err)
+
+	result := Rewrite(expr, func(node Node) Node {
+		switch node := node.(type) {
+		case *Call:
+			if node.Name == "lowercase" {
+				node.Name = "lower"
+			}
+		case *Value:
+			if node.String != nil {
+				upper := strings.ToUpper(*node.String)
+				return &Value{Pos: node.Pos, String: &upper}
+			}
+		case *Unary:
+			if node.Value != nil && node.Value.Variable != nil && *node.Value.Variable == "e" {
+				not := "!"
+				return &Unary{Pos: node.Pos, Op: &not, Unary: node}
+			}
+		}
+		return node
+	})
+	assert.True(result == expr, "expected the root to be rewritten in place")
+	assert.Equal(`lower(a) == "X" && b in lower(c) && d in [e, "Y"]`, Format(expr))
+
+	result = Rewrite(expr, func(node Node) Node {
+		if node, ok := node.(*Expression); ok && node.Next != nil {
+			return node.Next
+		}
+		return node
+	})
+	assert.Equal(`d in [e, "Y"]`, Format(result))
+}
+
+func TestRewritePanicsOnTypeMismatch(t *testing.T) {
+	assert := assert.New(t)
+	expr, err := ParseExpression(`a == b`)
+	assert.NoError(err)
+
+	assert.PanicsWithValue("cannot replace *main.Value with *main.Call", func() {
+		Rewrite(expr, func(node Node) Node {
+			if _, ok := node.(*Value); ok {
+				return &Call{Name: "f"}
+			}
+			return node
+		})
+	})
+}